@@ -2,17 +2,38 @@ package main
 
 import (
 	"context"
+	idempotencyKeysRepo "hexagon-architecture/internal/domain/idempotencykeys/repository"
 	productsRepo "hexagon-architecture/internal/domain/products/repository"
+	reservationsRepo "hexagon-architecture/internal/domain/reservations/repository"
+	stockMovementsRepo "hexagon-architecture/internal/domain/stockmovements/repository"
+	warehousesRepo "hexagon-architecture/internal/domain/warehouses/repository"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"hexagon-architecture/config"
 	"hexagon-architecture/internal/api"
+	idempotencyKeysDB "hexagon-architecture/internal/domain/idempotencykeys/repository/db"
+	idempotencyKeysMemory "hexagon-architecture/internal/domain/idempotencykeys/repository/memory"
 	productsDB "hexagon-architecture/internal/domain/products/repository/db"
+	productsMemory "hexagon-architecture/internal/domain/products/repository/memory"
+	reservationsDB "hexagon-architecture/internal/domain/reservations/repository/db"
+	reservationsMemory "hexagon-architecture/internal/domain/reservations/repository/memory"
+	stockMovementsDB "hexagon-architecture/internal/domain/stockmovements/repository/db"
+	stockMovementsMemory "hexagon-architecture/internal/domain/stockmovements/repository/memory"
+	warehousesDB "hexagon-architecture/internal/domain/warehouses/repository/db"
+	warehousesMemory "hexagon-architecture/internal/domain/warehouses/repository/memory"
 	"hexagon-architecture/internal/infrastructure"
 	"hexagon-architecture/internal/service"
+	"hexagon-architecture/pkg/health"
 	"hexagon-architecture/pkg/http"
+	"hexagon-architecture/pkg/transaction"
+
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
 
 	"github.com/gofiber/contrib/otelfiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -20,31 +41,151 @@ import (
 )
 
 func main() {
-// Get config.
-	cfg := config.GetConfig()
+	// Get config.
+	cfg, err := config.GetConfig()
+	if err != nil {
+		slog.Error("failed to load config", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// Init health checks.
+	checks := health.New(health.Config{Timeout: cfg.Health.Timeout})
+	for _, path := range cfg.Health.DiskPaths {
+		checks.Register("disk:"+path, true, health.DiskSpace(path, cfg.Health.DiskMinFree))
+	}
+
+	// Disconnects the database on shutdown, nil if there is none.
+	var closeDB func(context.Context) error
+
+	// Init products, stock movements, reservations, warehouses, idempotency
+	// keys and the transactions spanning them.
+	var products productsRepo.Repository
+	var stockMovements stockMovementsRepo.Repository
+	var reservations reservationsRepo.Repository
+	var warehouses warehousesRepo.Repository
+	var idempotencyKeys idempotencyKeysRepo.Repository
+	var tx transaction.Transaction
+	switch cfg.DB.Driver {
+	case "memory":
+		productsStore := productsMemory.New()
+		stockMovementsStore := stockMovementsMemory.New()
+		reservationsStore := reservationsMemory.New()
+		products = productsStore
+		stockMovements = stockMovementsStore
+		reservations = reservationsStore
+		warehouses = warehousesMemory.New()
+		idempotencyKeys = idempotencyKeysMemory.New()
+		tx = transaction.NewMemory(productsStore, stockMovementsStore, reservationsStore)
+	default:
+		// Init db.
+		db, err := config.NewDB(cfg.DB)
+		if err != nil {
+			slog.Error("failed to init database", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		checks.Register("mongo", true, func(ctx context.Context) error {
+			return db.Client().Ping(ctx, nil)
+		})
+		closeDB = db.Client().Disconnect
+
+		productsMongo := productsDB.New(db, "products")
+		if err := productsMongo.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("failed to ensure products indexes", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+
+		products = productsMongo
+
+		stockMovementsMongo := stockMovementsDB.New(db, "stock_movements")
+		if err := stockMovementsMongo.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("failed to ensure stock movements indexes", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		stockMovements = stockMovementsMongo
+
+		reservationsMongo := reservationsDB.New(db, "reservations")
+		if err := reservationsMongo.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("failed to ensure reservations indexes", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		reservations = reservationsMongo
 
-	// Init db.
-	db, _ := config.NewDB(cfg.DB, cfg.App.Env)
+		warehousesMongo := warehousesDB.New(db, "warehouses")
+		if err := warehousesMongo.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("failed to ensure warehouses indexes", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		warehouses = warehousesMongo
+
+		idempotencyKeysMongo := idempotencyKeysDB.New(db, "idempotency_keys")
+		if err := idempotencyKeysMongo.EnsureIndexes(context.Background()); err != nil {
+			slog.Error("failed to ensure idempotency keys indexes", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		idempotencyKeys = idempotencyKeysMongo
 
-	// Init products.
-	var products productsRepo.Repository = productsDB.New(db, "products")
+		tx = transaction.NewMongo(db.Client())
+	}
 
 	// Init service.
 	service := service.New(
 		products,
+		stockMovements,
+		reservations,
+		warehouses,
+		idempotencyKeys,
+		tx,
+		service.Config{
+			PurgeRetention:    cfg.Product.PurgeRetention,
+			ReservationTTL:    cfg.Reservation.TTL,
+			SuggestCacheTTL:   cfg.Product.SuggestCacheTTL,
+			StatsCacheTTL:     cfg.Product.StatsCacheTTL,
+			IdempotencyKeyTTL: cfg.Idempotency.KeyTTL,
+		},
 	)
 
 	ctx := context.Background()
 
+	// Run reservation sweeper until shutdown.
+	sweeperCtx, stopSweeper := context.WithCancel(ctx)
+	sweeperDone := make(chan struct{})
+	go func() {
+		defer close(sweeperDone)
+
+		// NewTicker panics unless the interval is positive, which config
+		// validation (gt=0) makes sure of.
+		ticker := time.NewTicker(cfg.Reservation.SweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-sweeperCtx.Done():
+				return
+			case <-ticker.C:
+				expired, _, err := service.ExpireReservations(sweeperCtx)
+				if err != nil {
+					slog.Error("failed to expire reservations", slog.String("error", err.Error()))
+				}
+				if expired > 0 {
+					slog.Info("expired reservations", slog.Int("count", expired))
+				}
+			}
+		}
+	}()
+
 	// Init Opentelemetry.
 	otelShutdown, err := infrastructure.SetupOTelSDK(ctx, cfg.App.Name, cfg.App.Version, cfg.Otel.Host, cfg.App.Env)
 	if err != nil {
-		return
+		slog.Error("failed to init opentelemetry", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	checks.Register("otel_exporter", false, infrastructure.CheckExporter)
+
+	// Report business gauges on every scrape.
+	if err := registerProductGauges(service); err != nil {
+		slog.Error("failed to register product gauges", slog.String("error", err.Error()))
+		os.Exit(1)
 	}
-	// Handle shutdown properly so nothing leaks.
-	defer func() {
-		err = otelShutdown(ctx)
-	}()
 
 	// Init web server.
 	httpServer := http.New(http.Config{
@@ -52,28 +193,165 @@ func main() {
 		ReadTimeout:     cfg.App.ReadTimeout,
 		WriteTimeout:    cfg.App.WriteTimeout,
 		GracefulTimeout: cfg.App.GracefulTimeout,
+		BodyLimit:       cfg.App.BodyLimit,
 	})
 	r := httpServer.Router()
 	r.Use(recover.New())
 	r.Use(logger.New(logger.Config{
 		Format: "[${time}] ${ip}  ${status} - ${latency} ${method} ${path}\n",
 	}))
-	r.Use(otelfiber.Middleware())
+	// HTTP metrics are recorded by the api with the status from the body,
+	// so otelfiber only traces.
+	r.Use(otelfiber.Middleware(otelfiber.WithMeterProvider(noop.NewMeterProvider())))
 
 	// Register api route.
-	api.New(service, cfg.App.Env).Register(r)
+	api.New(service, checks, cfg.App.Env, api.Auth{
+		APIKeys: cfg.Auth.APIKeys,
+		Admins:  cfg.Auth.Admins,
+	}).Register(r)
+
+	// Serve metrics on the app port unless they have their own.
+	var metricsServer http.Server
+	metricsServerChan := make(chan error)
+	if cfg.Metrics.Port == "" {
+		r.Get("/metrics", adaptor.HTTPHandler(infrastructure.MetricsHandler()))
+	} else {
+		metricsServer = http.New(http.Config{
+			Port:            cfg.Metrics.Port,
+			ReadTimeout:     cfg.App.ReadTimeout,
+			WriteTimeout:    cfg.App.WriteTimeout,
+			GracefulTimeout: cfg.App.GracefulTimeout,
+		})
+		metricsServer.Router().Get("/metrics", adaptor.HTTPHandler(infrastructure.MetricsHandler()))
+		metricsServerChan = metricsServer.Run()
+	}
 
 	// Run web server.
 	httpServerChan := httpServer.Run()
 
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	exitCode := 0
 	select {
 	case err := <-httpServerChan:
 		if err != nil {
-			return
+			slog.Error("http server stopped", slog.String("error", err.Error()))
+			exitCode = 1
+		}
+	case err := <-metricsServerChan:
+		if err != nil {
+			slog.Error("metrics server stopped", slog.String("error", err.Error()))
+			exitCode = 1
+		}
+	case sig := <-sigChan:
+		slog.Info("received signal", slog.String("signal", sig.String()))
+
+		// Fail readiness first so traffic moves away before shutdown.
+		checks.Drain()
+		slog.Info("draining", slog.String("delay", cfg.Health.DrainDelay.String()))
+		time.Sleep(cfg.Health.DrainDelay)
+	}
+
+	// Shut down in order: stop taking requests and let those in flight
+	// finish, stop background work, flush telemetry, then close the
+	// database everything else was using.
+	steps := []shutdownStep{
+		{name: "http server", fn: func(context.Context) error { return httpServer.Close() }},
+	}
+	if metricsServer != nil {
+		steps = append(steps, shutdownStep{name: "metrics server", fn: func(context.Context) error { return metricsServer.Close() }})
+	}
+	steps = append(steps,
+		shutdownStep{name: "reservation sweeper", fn: func(ctx context.Context) error {
+			stopSweeper()
+			<-sweeperDone
+			return nil
+		}},
+		shutdownStep{name: "opentelemetry", fn: otelShutdown},
+	)
+	if closeDB != nil {
+		steps = append(steps, shutdownStep{name: "database", fn: closeDB})
+	}
+
+	if !shutdown(steps, cfg.App.GracefulTimeout) {
+		exitCode = 1
+	}
+
+	os.Exit(exitCode)
+}
+
+// shutdownStep is one step of the shutdown sequence.
+type shutdownStep struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// shutdown to run steps one after another, each given at most timeout,
+// and log how each went. It returns false if any step failed.
+func shutdown(steps []shutdownStep, timeout time.Duration) bool {
+	ok := true
+	for _, step := range steps {
+		startTime := time.Now()
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := step.fn(ctx)
+		cancel()
+
+		if err != nil {
+			ok = false
+			slog.Error("failed to shut down",
+				slog.String("step", step.name),
+				slog.String("duration", time.Since(startTime).String()),
+				slog.String("error", err.Error()),
+			)
+			continue
 		}
-	case <-sigChan:
+
+		slog.Info("shut down",
+			slog.String("step", step.name),
+			slog.String("duration", time.Since(startTime).String()),
+		)
+	}
+
+	slog.Info("shutdown complete")
+	return ok
+}
+
+// registerProductGauges to report product counts on every metrics
+// collection.
+func registerProductGauges(svc service.Service) error {
+	meter := infrastructure.Meter()
+
+	total, err := meter.Int64ObservableGauge("store.products",
+		metric.WithUnit("{product}"),
+		metric.WithDescription("Number of products, deleted ones excluded."),
+	)
+	if err != nil {
+		return err
 	}
 
-}
\ No newline at end of file
+	outOfStock, err := meter.Int64ObservableGauge("store.products.out_of_stock",
+		metric.WithUnit("{product}"),
+		metric.WithDescription("Number of products with no stock left."),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		// Collection has no deadline of its own.
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+
+		stats, _, err := svc.GetProductStats(ctx)
+		if err != nil {
+			return err
+		}
+
+		o.ObserveInt64(total, int64(stats.Total))
+		o.ObserveInt64(outOfStock, int64(stats.OutOfStock))
+		return nil
+	}, total, outOfStock)
+	return err
+}
@@ -4,23 +4,35 @@ package config
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"log/slog"
+	"os"
 	"time"
 
 	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
 
+	"github.com/cenkalti/backoff/v4"
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 type config struct {
-	App  appConfig  `envconfig:"APP"`
-	Otel otelConfig `envconfig:"OTEL"`
-	DB   dbConfig   `envconfig:"DB"`
+	App         appConfig         `envconfig:"APP"`
+	Otel        otelConfig        `envconfig:"OTEL"`
+	DB          dbConfig          `envconfig:"DB"`
+	Product     productConfig     `envconfig:"PRODUCT"`
+	Reservation reservationConfig `envconfig:"RESERVATION"`
+	Idempotency idempotencyConfig `envconfig:"IDEMPOTENCY"`
+	Health      healthConfig      `envconfig:"HEALTH"`
+	Metrics     metricsConfig     `envconfig:"METRICS"`
+	Auth        authConfig        `envconfig:"AUTH"`
 }
 
 type appConfig struct {
@@ -29,9 +41,12 @@ type appConfig struct {
 	ReadTimeout     time.Duration `envconfig:"READ_TIMEOUT" default:"5s" validate:"required,gt=0"`
 	WriteTimeout    time.Duration `envconfig:"WRITE_TIMEOUT" default:"5s" validate:"required,gt=0"`
 	GracefulTimeout time.Duration `envconfig:"GRACEFUL_TIMEOUT" default:"10s" validate:"required,gt=0"`
-	Host            string        `envconfig:"HOST" validate:"required,url"`
-	Name            string        `envconfig:"NAME" validate:"required"`
-	Version         string        `envconfig:"VERSION" validate:"required"`
+	// BodyLimit is the largest request body read, in bytes. Larger ones
+	// are rejected before they are read whole.
+	BodyLimit int    `envconfig:"BODY_LIMIT" default:"4194304" validate:"required,gt=0"`
+	Host      string `envconfig:"HOST" validate:"required,url"`
+	Name      string `envconfig:"NAME" validate:"required"`
+	Version   string `envconfig:"VERSION" validate:"required"`
 }
 
 type otelConfig struct {
@@ -39,87 +54,248 @@ type otelConfig struct {
 }
 
 type dbConfig struct {
-	Address      string        `envconfig:"ADDRESS" default:"localhost:27018" validate:"required"`
-	Name            string        `envconfig:"NAME" default:"store" validate:"required"`
-	// User            string        `envconfig:"USER" default:"root" validate:"required"`
-	// Password        string        `envconfig:"PASSWORD"`
-	SslMode         string        `envconfig:"SSL_MODE" default:"disable"`
+	Driver string `envconfig:"DRIVER" default:"mongo" validate:"required,oneof=mongo memory" mod:"no_space,lcase"`
+	// URI is a full connection string, e.g. mongodb+srv://..., and takes
+	// the place of Address. The settings below override its options.
+	URI     string `envconfig:"URI" mod:"no_space"`
+	Address string `envconfig:"ADDRESS" default:"localhost:27018" validate:"required_without=URI"`
+	Name    string `envconfig:"NAME" default:"store" validate:"required"`
+	// Auth fields are not named USER/PASSWORD since envconfig falls back to
+	// the unprefixed name, which would pick up the shell $USER.
+	Username        string        `envconfig:"AUTH_USERNAME"`
+	Password        string        `envconfig:"AUTH_PASSWORD"`
+	AuthSource      string        `envconfig:"AUTH_SOURCE"`
+	SslMode         string        `envconfig:"SSL_MODE" default:"disable" validate:"oneof=disable require verify-full" mod:"no_space,lcase"`
+	TLSCAFile       string        `envconfig:"TLS_CA_FILE"`
+	TLSCertFile     string        `envconfig:"TLS_CERT_FILE"`
+	TLSKeyFile      string        `envconfig:"TLS_KEY_FILE"`
+	ReplicaSet      string        `envconfig:"REPLICA_SET" mod:"no_space"`
+	ReadPreference  string        `envconfig:"READ_PREFERENCE" validate:"omitempty,oneof=primary primaryPreferred secondary secondaryPreferred nearest" mod:"no_space"`
 	MaxConnOpen     int           `envconfig:"MAX_CONN_OPEN" default:"10" validate:"required,gt=0"`
-	MaxConnIdle     int           `envconfig:"MAX_CONN_IDLE" default:"10" validate:"required,gt=0"`
-	MaxConnLifetime time.Duration `envconfig:"MAX_CONN_LIFETIME" default:"60s" validate:"required,gt=0"`
+	MaxConnIdleTime time.Duration `envconfig:"MAX_CONN_IDLE_TIME" default:"60s" validate:"required,gt=0"`
+	ConnectTimeout  time.Duration `envconfig:"CONNECT_TIMEOUT" default:"10s" validate:"required,gt=0"`
+	// RetryMaxAttempts is how many times a failed connection is retried,
+	// 0 to give up on the first failure.
+	RetryMaxAttempts     int           `envconfig:"RETRY_MAX_ATTEMPTS" default:"5" validate:"gte=0"`
+	RetryInitialInterval time.Duration `envconfig:"RETRY_INITIAL_INTERVAL" default:"1s" validate:"required,gt=0"`
+	RetryMaxInterval     time.Duration `envconfig:"RETRY_MAX_INTERVAL" default:"30s" validate:"required,gt=0"`
+}
+
+type productConfig struct {
+	PurgeRetention  time.Duration `envconfig:"PURGE_RETENTION" default:"720h" validate:"required,gt=0"`
+	SuggestCacheTTL time.Duration `envconfig:"SUGGEST_CACHE_TTL" default:"30s" validate:"gte=0"`
+	StatsCacheTTL   time.Duration `envconfig:"STATS_CACHE_TTL" default:"1m" validate:"gte=0"`
+}
+
+type reservationConfig struct {
+	TTL time.Duration `envconfig:"TTL" default:"10m" validate:"required,gt=0"`
+	// SweepInterval must stay gt=0, the sweeper ticker panics otherwise.
+	SweepInterval time.Duration `envconfig:"SWEEP_INTERVAL" default:"30s" validate:"required,gt=0"`
+}
+
+type idempotencyConfig struct {
+	KeyTTL time.Duration `envconfig:"KEY_TTL" default:"24h" validate:"required,gt=0"`
+}
+
+type healthConfig struct {
+	Timeout     time.Duration `envconfig:"TIMEOUT" default:"2s" validate:"required,gt=0"`
+	DrainDelay  time.Duration `envconfig:"DRAIN_DELAY" default:"5s" validate:"gte=0"`
+	DiskPaths   []string      `envconfig:"DISK_PATHS"`
+	DiskMinFree uint64        `envconfig:"DISK_MIN_FREE" default:"104857600"`
+}
+
+type metricsConfig struct {
+	// Port serves /metrics on its own port, e.g. to keep it off the public
+	// one. Empty serves it on the app port.
+	Port string `envconfig:"PORT" mod:"no_space"`
+}
+
+type authConfig struct {
+	// APIKeys is the key of each user, as user:key pairs separated by
+	// commas. Requests without a key are anonymous.
+	APIKeys map[string]string `envconfig:"API_KEYS" validate:"dive,required"`
+	// Admins are users allowed on admin routes.
+	Admins []string `envconfig:"ADMINS"`
 }
 
 const envPrefix = "STORE"
 
-func GetConfig() *config {
+// GetConfig to load config from env and validate it. Errors are meant
+// to stop the service from starting.
+func GetConfig() (*config, error) {
 	var cfg config
 
+	// Init global log first so config errors are logged the same way.
+	infrastructure.InitSlog()
+
 	// Load .env file.
 	_ = godotenv.Load()
 
 	// Convert env to struct.
 	if err := envconfig.Process(envPrefix, &cfg); err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	// Validate and clean up the values.
+	if err := utils.Validate(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// NewDB to connect to the database. Failed attempts are retried with
+// exponential backoff until cfg.RetryMaxAttempts retries are used up.
+func NewDB(cfg dbConfig) (*mongo.Database, error) {
+	dbOptions, err := mongoOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	dbOptions.Monitor = otelmongo.NewMonitor()
+
+	var dbClient *mongo.Client
+	attempts := 0
+	connect := func() error {
+		attempts++
+
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+		defer cancel()
+
+		client, err := mongo.Connect(ctx, dbOptions)
+		if err != nil {
+			// Connect doesn't reach the server, so retrying won't help.
+			return backoff.Permanent(err)
+		}
+
+		if err := client.Ping(ctx, nil); err != nil {
+			_ = client.Disconnect(context.Background())
+			return err
+		}
+
+		dbClient = client
 		return nil
 	}
 
-	// Init global log.
-	infrastructure.InitSlog()
+	retry := backoff.NewExponentialBackOff()
+	retry.InitialInterval = cfg.RetryInitialInterval
+	retry.MaxInterval = cfg.RetryMaxInterval
+	retry.MaxElapsedTime = 0
+
+	notify := func(err error, next time.Duration) {
+		slog.Warn("failed to connect to database",
+			slog.Int("attempt", attempts),
+			slog.String("retry_in", next.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	if err := backoff.RetryNotify(connect, backoff.WithMaxRetries(retry, uint64(cfg.RetryMaxAttempts)), notify); err != nil {
+		return nil, fmt.Errorf("connect to database after %d attempts: %w", attempts, err)
+	}
 
-	return &cfg
+	slog.Info("connected to database", slog.String("name", cfg.Name), slog.Int("attempts", attempts))
+
+	return dbClient.Database(cfg.Name), nil
 }
 
-func NewDB(cfg dbConfig, env string) (*mongo.Database, error) {
+// mongoOptions to build the client options from cfg. The URI, or the
+// address when there is none, is applied first so the settings set in
+// cfg win over the options it carries, and the ones left empty keep them.
+func mongoOptions(cfg dbConfig) (*options.ClientOptions, error) {
+	uri := cfg.URI
+	if uri == "" {
+		uri = fmt.Sprintf("mongodb://%s", cfg.Address)
+	}
 
-	// dbOptions to setup mongodb uri
-	var dns string
-	switch env {
-	case "local", "development":
-		dns = fmt.Sprintf("mongodb://%s", cfg.Address)
-	default:
+	dbOptions := options.Client().ApplyURI(uri)
+
+	// Credentials in the uri are changed rather than replaced, so its auth
+	// mechanism and source are kept unless set here.
+	if cfg.Username != "" || dbOptions.Auth != nil {
+		var credential options.Credential
+		if dbOptions.Auth != nil {
+			credential = *dbOptions.Auth
+		}
+		if cfg.Username != "" {
+			credential.Username = cfg.Username
+			credential.Password = cfg.Password
+			credential.PasswordSet = cfg.Password != ""
+		}
+		if cfg.AuthSource != "" {
+			credential.AuthSource = cfg.AuthSource
+		}
+		dbOptions.SetAuth(credential)
 	}
 
-	dbOptions := options.Client()
-	dbOptions.Monitor = otelmongo.NewMonitor()
-	dbOptions.ApplyURI(dns)
+	if cfg.SslMode != "" && cfg.SslMode != "disable" {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		dbOptions.SetTLSConfig(tlsConfig)
+	}
 
-	// Prepare dns and open connection.
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	if cfg.ReplicaSet != "" {
+		dbOptions.SetReplicaSet(cfg.ReplicaSet)
+	}
 
-	// connection to mongo database
-	dbClient, err := mongo.Connect(ctx, dbOptions)
-	if err != nil {
-		fmt.Println("=========== Failed to connect to database ", err)
-		return nil, errors.Join(err)
+	if cfg.ReadPreference != "" {
+		mode, err := readpref.ModeFromString(cfg.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		readPreference, err := readpref.New(mode)
+		if err != nil {
+			return nil, err
+		}
+		dbOptions.SetReadPreference(readPreference)
 	}
 
-	fmt.Println("=========== success to connect to database ")
+	dbOptions.SetMaxPoolSize(uint64(cfg.MaxConnOpen))
+	dbOptions.SetMaxConnIdleTime(cfg.MaxConnIdleTime)
 
-	//tess
-	err = dbClient.Ping(context.Background(), nil)
-	if err != nil {
-		fmt.Println("=========== Failed to ping database: ", err)
+	if err := dbOptions.Validate(); err != nil {
 		return nil, err
 	}
 
-	fmt.Println("=========== success ping to connect to database ")
+	return dbOptions, nil
+}
 
-	// Test ping to the database
-	// err = dbClient.Ping(ctx, nil)
-	// if err != nil {
-	// 	fmt.Println("=========== Failed to ping database: ", err)
+// newTLSConfig to build the TLS config for the ssl mode. require encrypts
+// without checking the server certificate, verify-full checks it against
+// the system roots or the CA file.
+func newTLSConfig(cfg dbConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.SslMode == "require",
+	}
 
-	// 	// Check if it's a server selection error
-	// 	if cmdErr, ok := err.(mongo.CommandError); ok && cmdErr.HasErrorLabel("ServerSelectionTimeout") {
-	// 		fmt.Printf("============== Server selection timeout error: %v\n", cmdErr)
-	// 		return nil, fmt.Errorf("Server selection timeout: %w", err)
-	// 	}
+	if cfg.TLSCAFile != "" {
+		ca, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls ca file: %w", err)
+		}
 
-	// 	return nil, err
-	// }
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificate found in tls ca file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
 
-	// fmt.Println("=========== success ping to connect to database ")
+	if cfg.TLSCertFile != "" {
+		// Mongo client certificates often come as one PEM with the key.
+		keyFile := cfg.TLSKeyFile
+		if keyFile == "" {
+			keyFile = cfg.TLSCertFile
+		}
 
-	return dbClient.Database(cfg.Name), nil
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load tls client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 
+	return tlsConfig, nil
 }
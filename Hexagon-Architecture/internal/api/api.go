@@ -3,42 +3,123 @@ package api
 import (
 	"net/http"
 
+	"hexagon-architecture/internal/domain/products/entity"
 	"hexagon-architecture/internal/infrastructure"
 	"hexagon-architecture/internal/service"
 	"hexagon-architecture/internal/utils"
+	"hexagon-architecture/pkg/health"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // API contains all functions for api endpoints.
 type API struct {
 	service service.Service
+	health  health.Health
 	env     string
+	auth    Auth
 }
 
 // New to create new api endpoints.
-func New(service service.Service, env string) *API {
+func New(service service.Service, health health.Health, env string, auth Auth) *API {
 	return &API{
 		service: service,
+		health:  health,
 		env:     env,
+		auth:    auth,
 	}
 }
 
 // Register to register api routes.
 func (api *API) Register(r *fiber.App) {
 	r.Route("/", func(router fiber.Router) {
+		router.Use(api.middlewareMetrics)
+		router.Use(api.middlewareRequestContext)
+		router.Use(api.middlewareAuth)
+		router.Use(api.middlewareIdempotency)
+
 		router.Get("/", api.handleRoot)
 		router.Get("/ping", api.handlePing)
+		router.Get("/healthz", api.handleHealthz)
+		router.Get("/readyz", api.handleReadyz)
 		router.Get("/favicon.ico", api.handleFavIcon)
 
-		router.Get("/product/:id", api.handleGetProduct)
+		router.Get("/product/:id", api.middlewareProductID, api.handleGetProduct)
 		router.Get("/products", api.handleGetProducts)
+		router.Get("/products/export", api.handleExportProducts)
+		router.Get("/products/search", api.handleSearchProducts)
+		router.Get("/products/suggest", api.handleSuggestProducts)
 		router.Post("/product", api.handleCreateProduct)
-		router.Put("/product/:id", api.handleUpdateProduct)
-		router.Delete("/product/:id", api.handleDeleteProduct)
+		router.Post("/products/bulk", api.handleBulkProducts)
+		router.Post("/products/import", api.handleImportProducts)
+		router.Put("/product/:id", api.middlewareProductID, api.handleReplaceProduct)
+		router.Patch("/product/:id", api.middlewareProductID, api.handlePatchProduct)
+		router.Delete("/product/:id", api.middlewareProductID, api.handleDeleteProduct)
+		router.Post("/product/:id/restore", api.middlewareProductID, api.handleRestoreProduct)
+		router.Post("/product/:id/stock/adjust", api.middlewareProductID, api.handleAdjustStock)
+		router.Post("/product/:id/stock/transfer", api.middlewareProductID, api.handleTransferStock)
+		router.Get("/product/:id/stock/movements", api.middlewareProductID, api.handleGetStockMovements)
+		router.Post("/product/:id/reservations", api.middlewareProductID, api.handleReserveStock)
+
+		router.Get("/reservations/:id", api.handleGetReservation)
+		router.Post("/reservations/:id/confirm", api.handleConfirmReservation)
+		router.Post("/reservations/:id/release", api.handleReleaseReservation)
+
+		router.Get("/warehouses", api.handleGetWarehouses)
+		router.Post("/warehouses", api.handleCreateWarehouse)
+
+		admin := router.Group("/admin", api.middlewareAdmin)
+		admin.Post("/products/purge", api.handlePurgeProducts)
 	})
 }
 
+// middlewareRequestContext puts the request correlation id in context.
+// The user who makes the request is put there by middlewareAuth.
+func (api *API) middlewareRequestContext(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	correlationID := c.Get("X-Correlation-ID")
+	if correlationID == "" {
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.HasTraceID() {
+			correlationID = spanCtx.TraceID().String()
+		} else {
+			correlationID = uuid.NewString()
+		}
+	}
+	c.Set("X-Correlation-ID", correlationID)
+
+	ctx = utils.SetCorrelationID(ctx, correlationID)
+	c.SetUserContext(ctx)
+
+	return c.Next()
+}
+
+// productIDLocal is the fiber local keeping the parsed product id.
+const productIDLocal = "productID"
+
+// middlewareProductID rejects product routes with a malformed id before
+// they reach the handler, so every route answers the same way. Handlers
+// get the parsed id with productID.
+func (api *API) middlewareProductID(c *fiber.Ctx) error {
+	id, err := entity.ParseProductID(c.Params("id"))
+	if err != nil {
+		utils.ResponseWithJSON(c, http.StatusBadRequest, nil, err)
+		return nil
+	}
+
+	c.Locals(productIDLocal, id)
+
+	return c.Next()
+}
+
+// productID is the product id parsed by middlewareProductID.
+func productID(c *fiber.Ctx) entity.ProductID {
+	id, _ := c.Locals(productIDLocal).(entity.ProductID)
+	return id
+}
+
 func (api *API) handleRoot(c *fiber.Ctx) error {
 	utils.ResponseWithJSON(c, http.StatusOK, "ok", nil)
 	return nil
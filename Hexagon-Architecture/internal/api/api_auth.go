@@ -0,0 +1,79 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Auth is who may call the api.
+type Auth struct {
+	// APIKeys is the key of each user, sent as a bearer token.
+	APIKeys map[string]string
+
+	// Admins are users allowed on admin routes.
+	Admins []string
+}
+
+// userLocal is the fiber local keeping the authenticated user.
+const userLocal = "user"
+
+// middlewareAuth sets the actor of the request from its bearer api key.
+// Requests without one stay anonymous, while a wrong key is rejected so a
+// typo doesn't quietly turn into an anonymous request.
+func (api *API) middlewareAuth(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	if header == "" {
+		return c.Next()
+	}
+
+	key, ok := strings.CutPrefix(header, "Bearer ")
+	user := api.userByKey(key)
+	if !ok || user == "" {
+		utils.ResponseWithJSON(c, http.StatusUnauthorized, nil, errors.ErrInvalidAPIKey)
+		return nil
+	}
+
+	c.Locals(userLocal, user)
+	c.SetUserContext(utils.SetActor(c.UserContext(), user))
+
+	return c.Next()
+}
+
+// middlewareAdmin only lets admins through.
+func (api *API) middlewareAdmin(c *fiber.Ctx) error {
+	user, _ := c.Locals(userLocal).(string)
+	if user == "" {
+		utils.ResponseWithJSON(c, http.StatusUnauthorized, nil, errors.ErrUnauthorized)
+		return nil
+	}
+
+	if !slices.Contains(api.auth.Admins, user) {
+		utils.ResponseWithJSON(c, http.StatusForbidden, nil, errors.ErrForbidden)
+		return nil
+	}
+
+	return c.Next()
+}
+
+// userByKey returns the user of an api key, empty if there is none. Every
+// key is compared in full so the time taken doesn't give away a match.
+func (api *API) userByKey(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	var user string
+	for u, k := range api.auth.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			user = u
+		}
+	}
+	return user
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"strings"
+
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxJSONBodySize is the largest JSON body accepted, enough for a full
+// bulk request. It is tighter than the server body limit, which is kept
+// larger for imports.
+const maxJSONBodySize = 1 << 20
+
+// bindJSON to decode a JSON request body into v. The body must be sent as
+// application/json, be no larger than maxJSONBodySize and have no fields
+// v doesn't know.
+func bindJSON(c *fiber.Ctx, v interface{}) (int, error) {
+	if !hasContentType(c, fiber.MIMEApplicationJSON) {
+		return fiber.StatusUnsupportedMediaType, errors.ErrUnsupportedMediaType
+	}
+
+	if code, err := checkBodySize(c); err != nil {
+		return code, err
+	}
+
+	if err := utils.DecodeJSON(c.Body(), v); err != nil {
+		return fiber.StatusBadRequest, err
+	}
+
+	return fiber.StatusOK, nil
+}
+
+// checkBodySize to reject bodies larger than maxJSONBodySize.
+func checkBodySize(c *fiber.Ctx) (int, error) {
+	if len(c.Body()) > maxJSONBodySize {
+		return fiber.StatusRequestEntityTooLarge, errors.ErrRequestBodyTooLarge
+	}
+	return fiber.StatusOK, nil
+}
+
+// hasContentType tells whether the request media type is mime, ignoring
+// parameters like charset.
+func hasContentType(c *fiber.Ctx, mime string) bool {
+	contentType, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	return strings.EqualFold(strings.TrimSpace(contentType), mime)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// handleHealthz answers as long as the process can serve requests.
+func (api *API) handleHealthz(c *fiber.Ctx) error {
+	utils.ResponseWithJSON(c, http.StatusOK, "ok", nil)
+	return nil
+}
+
+// handleReadyz runs the dependency checks. Unlike other endpoints the
+// HTTP status is set too, since probes only look at it.
+func (api *API) handleReadyz(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "health:handleReadyz")
+	defer span.End()
+
+	report := api.health.Check(ctx)
+	if !report.Ready {
+		c.Status(http.StatusServiceUnavailable)
+		utils.ResponseWithJSON(c, http.StatusServiceUnavailable, report, nil)
+		return nil
+	}
+
+	utils.ResponseWithJSON(c, http.StatusOK, report, nil)
+	return nil
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// middlewareIdempotency runs a mutating request with an Idempotency-Key
+// header only once. Retries with the same key get the first response
+// back, while a different request with the same key is rejected.
+func (api *API) middlewareIdempotency(c *fiber.Ctx) error {
+	key := c.Get(headerIdempotencyKey)
+	if key == "" {
+		return c.Next()
+	}
+
+	switch c.Method() {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+	default:
+		return c.Next()
+	}
+
+	ctx := c.UserContext()
+
+	saved, code, err := api.service.StartIdempotentRequest(ctx, key, requestHash(c))
+	if err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+	if saved != nil {
+		c.Status(saved.Status)
+		c.Set(fiber.HeaderContentType, saved.ContentType)
+		if saved.ETag != "" {
+			c.Set(fiber.HeaderETag, saved.ETag)
+		}
+		c.Set(headerIdempotentReplayed, "true")
+		return c.Send(saved.Body)
+	}
+
+	// A panicking handler frees the key too, or retries would be told it
+	// is in progress until it expires. The recover middleware still gets
+	// the panic.
+	defer func() {
+		if r := recover(); r != nil {
+			api.cancelIdempotentRequest(ctx, key)
+			panic(r)
+		}
+	}()
+
+	// Server errors are worth retrying, so they free the key instead of
+	// being replayed.
+	if err := c.Next(); err != nil || responseCode(c) >= fiber.StatusInternalServerError {
+		api.cancelIdempotentRequest(ctx, key)
+		return err
+	}
+
+	if _, err := api.service.FinishIdempotentRequest(ctx, key, service.IdempotentResponse{
+		Status:      c.Response().StatusCode(),
+		ContentType: string(c.Response().Header.ContentType()),
+		ETag:        string(c.Response().Header.Peek(fiber.HeaderETag)),
+		Body:        c.Response().Body(),
+	}); err != nil {
+		slog.ErrorContext(ctx, "failed to save idempotent response",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	return nil
+}
+
+// cancelIdempotentRequest to free key for a retry.
+func (api *API) cancelIdempotentRequest(ctx context.Context, key string) {
+	if _, err := api.service.CancelIdempotentRequest(ctx, key); err != nil {
+		slog.ErrorContext(ctx, "failed to cancel idempotent request",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// requestHash tells apart requests reusing the same idempotency key.
+func requestHash(c *fiber.Ctx) string {
+	h := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(c.Method()),
+		[]byte(c.OriginalURL()),
+		[]byte(utils.GetActor(c.UserContext())),
+		c.Request().Header.ContentType(),
+		c.Body(),
+	} {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseCode returns the status of the response. JSON responses keep
+// their real status in the body and are always sent with 200.
+func responseCode(c *fiber.Ctx) int {
+	code := c.Response().StatusCode()
+	if code != fiber.StatusOK {
+		return code
+	}
+
+	if status := utils.ResponseStatus(c); status != 0 {
+		return status
+	}
+
+	// Reading a streamed body would consume it.
+	if c.Response().IsBodyStream() {
+		return code
+	}
+
+	var response struct {
+		Status int `json:"status"`
+	}
+	if err := json.Unmarshal(c.Response().Body(), &response); err == nil && response.Status != 0 {
+		return response.Status
+	}
+	return code
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/valyala/fasthttp"
+)
+
+func TestIdempotencyReplay(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	body := `{"sku":"APL-1","name_product":"Apple","stock":1}`
+	headers := map[string]string{headerIdempotencyKey: "create-apple"}
+
+	resp, first := doRequest(t, app, fiber.MethodPost, "/product", body, headers)
+	assert.Equal(t, http.StatusOK, first.Status)
+	assert.Empty(t, resp.Header.Get(headerIdempotentReplayed))
+	etag := resp.Header.Get(fiber.HeaderETag)
+
+	resp, second := doRequest(t, app, fiber.MethodPost, "/product", body, headers)
+	assert.Equal(t, "true", resp.Header.Get(headerIdempotentReplayed))
+	assert.Equal(t, etag, resp.Header.Get(fiber.HeaderETag))
+	assert.Equal(t, first, second)
+
+	// The replay didn't create the product again, or the SKU would clash.
+	_, products := doRequest(t, app, fiber.MethodGet, "/products", "", nil)
+	assert.Len(t, products.Data, 1)
+}
+
+func TestIdempotencyKeyReused(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	headers := map[string]string{headerIdempotencyKey: "create-fruit"}
+
+	_, response := doRequest(t, app, fiber.MethodPost, "/product", `{"sku":"APL-1","name_product":"Apple","stock":1}`, headers)
+	assert.Equal(t, http.StatusOK, response.Status)
+
+	resp, response := doRequest(t, app, fiber.MethodPost, "/product", `{"sku":"PR-1","name_product":"Pear","stock":1}`, headers)
+	assert.Equal(t, http.StatusUnprocessableEntity, response.Status)
+	assert.Equal(t, errors.ErrIdempotencyKeyReused.Error(), response.Message)
+	assert.Empty(t, resp.Header.Get(headerIdempotentReplayed))
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	app.Post("/test/slow", func(c *fiber.Ctx) error {
+		close(started)
+		<-finish
+		utils.ResponseWithJSON(c, http.StatusOK, nil, nil)
+		return nil
+	})
+
+	headers := map[string]string{headerIdempotencyKey: "slow"}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, response := doRequest(t, app, fiber.MethodPost, "/test/slow", "{}", headers)
+		assert.Equal(t, http.StatusOK, response.Status)
+	}()
+
+	<-started
+	_, response := doRequest(t, app, fiber.MethodPost, "/test/slow", "{}", headers)
+	assert.Equal(t, http.StatusConflict, response.Status)
+	assert.Equal(t, errors.ErrIdempotencyKeyInProgress.Error(), response.Message)
+
+	close(finish)
+	wg.Wait()
+
+	resp, response := doRequest(t, app, fiber.MethodPost, "/test/slow", "{}", headers)
+	assert.Equal(t, http.StatusOK, response.Status)
+	assert.Equal(t, "true", resp.Header.Get(headerIdempotentReplayed))
+}
+
+func TestIdempotencyCancel(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	var calls int
+	app.Post("/test/flaky", func(c *fiber.Ctx) error {
+		calls++
+		if calls == 1 {
+			utils.ResponseWithJSON(c, http.StatusServiceUnavailable, nil, errors.ErrInternalDB)
+			return nil
+		}
+		utils.ResponseWithJSON(c, http.StatusOK, calls, nil)
+		return nil
+	})
+	app.Post("/test/panic", func(c *fiber.Ctx) error {
+		calls++
+		if calls == 1 {
+			panic("boom")
+		}
+		utils.ResponseWithJSON(c, http.StatusOK, calls, nil)
+		return nil
+	})
+
+	for _, path := range []string{"/test/flaky", "/test/panic"} {
+		t.Run(path, func(t *testing.T) {
+			calls = 0
+			headers := map[string]string{headerIdempotencyKey: path}
+
+			resp, err := app.Test(newRequest(t, fiber.MethodPost, path, "", headers), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_ = resp.Body.Close()
+			assert.Equal(t, 1, calls)
+
+			// The failure freed the key, so the retry runs instead of being
+			// told the request is in progress or getting the error back.
+			resp, response := doRequest(t, app, fiber.MethodPost, path, "", headers)
+			assert.Equal(t, http.StatusOK, response.Status)
+			assert.Equal(t, float64(2), response.Data)
+			assert.Empty(t, resp.Header.Get(headerIdempotentReplayed))
+		})
+	}
+}
+
+func TestResponseCode(t *testing.T) {
+	app := fiber.New()
+
+	tests := map[string]struct {
+		write        func(c *fiber.Ctx)
+		expectedCode int
+	}{
+		"ok": {
+			write:        func(c *fiber.Ctx) { _ = c.SendString("ok") },
+			expectedCode: http.StatusOK,
+		},
+		"http-status": {
+			write:        func(c *fiber.Ctx) { _ = c.Status(http.StatusBadGateway).SendString("bad gateway") },
+			expectedCode: http.StatusBadGateway,
+		},
+		"response-status": {
+			write: func(c *fiber.Ctx) {
+				utils.ResponseWithJSON(c, http.StatusInternalServerError, nil, errors.ErrInternalDB)
+			},
+			expectedCode: http.StatusInternalServerError,
+		},
+		"body-status": {
+			write:        func(c *fiber.Ctx) { _ = c.Send([]byte(`{"status":503,"message":"not ready"}`)) },
+			expectedCode: http.StatusServiceUnavailable,
+		},
+		"body-not-json": {
+			write:        func(c *fiber.Ctx) { _ = c.SendString(`status: 503`) },
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := app.AcquireCtx(&fasthttp.RequestCtx{})
+			defer app.ReleaseCtx(c)
+
+			test.write(c)
+			assert.Equal(t, test.expectedCode, responseCode(c))
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"hexagon-architecture/internal/infrastructure"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// middlewareMetrics records request count, latency and size per route.
+// The status is the one in the response body, since most errors are sent
+// with HTTP status 200.
+func (api *API) middlewareMetrics(c *fiber.Ctx) error {
+	startTime := time.Now()
+
+	err := c.Next()
+
+	code := responseCode(c)
+	if err != nil {
+		code = fiber.StatusInternalServerError
+		if e, ok := err.(*fiber.Error); ok {
+			code = e.Code
+		}
+	}
+
+	// The size of a streamed body isn't known until it is sent.
+	responseSize := -1
+	if !c.Response().IsBodyStream() {
+		responseSize = len(c.Response().Body())
+	}
+
+	// Method is copied since fiber reuses its buffer once the request is
+	// done.
+	infrastructure.ObserveHTTPRequest(c.UserContext(),
+		strings.Clone(c.Method()),
+		c.Route().Path,
+		code,
+		time.Since(startTime),
+		len(c.Request().Body()),
+		responseSize,
+	)
+
+	return err
+}
@@ -1,22 +1,73 @@
 package api
 
 import (
-	"encoding/json"
+	"bytes"
+	"encoding/csv"
+	"hexagon-architecture/internal/errors"
 	"hexagon-architecture/internal/infrastructure"
 	"hexagon-architecture/internal/service"
 	"hexagon-architecture/internal/utils"
+	"io"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// productsFilterQuery is query parameters to filter and sort products.
+var productsFilterQuery = []string{
+	"name", "match", "sku", "category", "tag", "currency", "sort",
+	"stock_lt", "stock_lte", "stock_gt", "stock_gte", "in_stock",
+}
+
+// getProductsQuery is query parameters GET /products accepts.
+var getProductsQuery = append([]string{"page", "limit", "cursor"}, productsFilterQuery...)
+
+// exportProductsQuery is query parameters GET /products/export accepts.
+var exportProductsQuery = append([]string{"format"}, productsFilterQuery...)
+
+// parseProductsFilter to read productsFilterQuery parameters.
+func parseProductsFilter(c *fiber.Ctx) (service.GetProductsRequest, error) {
+	request := service.GetProductsRequest{
+		Name:     c.Query("name"),
+		Match:    c.Query("match"),
+		SKU:      c.Query("sku"),
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		Currency: c.Query("currency"),
+		Sort:     c.Query("sort"),
+	}
+
+	var err error
+	for key, value := range map[string]**int{
+		"stock_lt":  &request.StockLT,
+		"stock_lte": &request.StockLTE,
+		"stock_gt":  &request.StockGT,
+		"stock_gte": &request.StockGTE,
+	} {
+		if *value, err = queryInt(c, key); err != nil {
+			return service.GetProductsRequest{}, err
+		}
+	}
+
+	if request.InStock, err = queryBool(c, "in_stock"); err != nil {
+		return service.GetProductsRequest{}, err
+	}
+
+	return request, nil
+}
+
 func (api *API) handleGetProduct(c *fiber.Ctx) error {
 	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleGetProduct")
 	defer span.End()
 
-	id := c.Params("id")
+	id := productID(c)
 
 	result, code, err := api.service.GetProduct(ctx, id)
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
 
 	utils.ResponseWithJSON(c, code, result, err, nil)
 	return nil
@@ -26,8 +77,6 @@ func (api *API) handleGetProducts(c *fiber.Ctx) error {
 	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleGetProducts")
 	defer span.End()
 
-	name := c.Query("name")
-
 	page, err := strconv.Atoi(c.Query("page"))
 	if err != nil || page <= 0 {
 		page = 1
@@ -44,10 +93,25 @@ func (api *API) handleGetProducts(c *fiber.Ctx) error {
 		// return nil
 	}
 
-	request := service.GetProductsRequest{
-		Page:  page,
-		Limit: limit,
-		Name:  name,
+	if err := checkQuery(c, getProductsQuery); err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	request, err := parseProductsFilter(c)
+	if err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+	request.Page = page
+	request.Limit = limit
+
+	// Cursor pagination is opt-in, an empty cursor asks for the first page.
+	if cursor, ok := c.Queries()["cursor"]; ok {
+		result, next, code, err := api.service.GetProductsByCursor(ctx, request, cursor)
+
+		utils.ResponseWithCursor(c, code, result, err, next)
+		return nil
 	}
 
 	result, pagination, code, err := api.service.GetProducts(ctx, request)
@@ -56,39 +120,262 @@ func (api *API) handleGetProducts(c *fiber.Ctx) error {
 	return nil
 }
 
+// searchProductsQuery is query parameters GET /products/search accepts.
+var searchProductsQuery = []string{"q", "category", "limit"}
+
+func (api *API) handleSearchProducts(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleSearchProducts")
+	defer span.End()
+
+	if err := checkQuery(c, searchProductsQuery); err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+	if limit == nil {
+		defaultLimit := 10
+		limit = &defaultLimit
+	}
+
+	result, code, err := api.service.SearchProducts(ctx, service.SearchProductsRequest{
+		Query:    c.Query("q"),
+		Category: c.Query("category"),
+		Limit:    *limit,
+	})
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+// suggestProductsQuery is query parameters GET /products/suggest accepts.
+var suggestProductsQuery = []string{"q", "limit"}
+
+func (api *API) handleSuggestProducts(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleSuggestProducts")
+	defer span.End()
+
+	if err := checkQuery(c, suggestProductsQuery); err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+	if limit == nil {
+		defaultLimit := 5
+		limit = &defaultLimit
+	}
+
+	result, code, err := api.service.SuggestProducts(ctx, service.SuggestProductsRequest{
+		Query: c.Query("q"),
+		Limit: *limit,
+	})
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
 func (api *API) handleCreateProduct(c *fiber.Ctx) error {
 	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleCreateProduct")
 	defer span.End()
 
 	var request service.CreateProductRequest
-	_ = json.Unmarshal(c.Body(), &request)
+	if code, err := bindJSON(c, &request); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
 
 	result, code, err := api.service.CreateProduct(ctx, request)
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
 
 	utils.ResponseWithJSON(c, code, result, err, nil)
 	return nil
 }
 
-func (api *API) handleUpdateProduct(c *fiber.Ctx) error {
-	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleUpdateProduct")
+func (api *API) handleBulkProducts(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleBulkProducts")
 	defer span.End()
 
-	id := c.Params("id")
+	var request service.BulkProductsRequest
+	if code, err := bindJSON(c, &request); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
 
-	var updateRequest service.UpdateProductRequest
-	_ = json.Unmarshal(c.Body(), &updateRequest)
+	result, code, err := api.service.BulkProducts(ctx, request)
 
-	result, code, err := api.service.UpdateProduct(ctx, id, updateRequest)
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+// importProductsQuery is query parameters POST /products/import accepts.
+var importProductsQuery = []string{"dry_run"}
+
+// handleImportProducts takes the CSV file as multipart form file "file" or
+// as a text/csv body. The summary is JSON unless the client accepts only
+// CSV, then it is the row error report.
+func (api *API) handleImportProducts(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleImportProducts")
+	defer span.End()
+
+	if err := checkQuery(c, importProductsQuery); err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	dryRun, err := queryBool(c, "dry_run")
+	if err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	file, code, err := importFile(c)
+	if err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+	defer file.Close()
+
+	result, code, err := api.service.ImportProducts(ctx, service.ImportProductsRequest{
+		File:   file,
+		DryRun: dryRun != nil && *dryRun,
+	})
+
+	if err == nil && c.Accepts(fiber.MIMEApplicationJSON, mimeTextCSV) == mimeTextCSV {
+		c.Attachment("import-errors.csv")
+		return writeImportReport(c, result.Errors)
+	}
 
 	utils.ResponseWithJSON(c, code, result, err, nil)
 	return nil
 }
 
+const mimeTextCSV = "text/csv"
+
+func importFile(c *fiber.Ctx) (io.ReadCloser, int, error) {
+	contentType := strings.ToLower(c.Get(fiber.HeaderContentType))
+	switch {
+	case strings.HasPrefix(contentType, fiber.MIMEMultipartForm):
+		header, err := c.FormFile("file")
+		if err != nil {
+			return nil, fiber.StatusBadRequest, errors.ErrRequiredField("file")
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, fiber.StatusBadRequest, errors.ErrInvalidRequestFormat
+		}
+		return file, fiber.StatusOK, nil
+	case strings.HasPrefix(contentType, mimeTextCSV):
+		return io.NopCloser(bytes.NewReader(c.Body())), fiber.StatusOK, nil
+	default:
+		return nil, fiber.StatusUnsupportedMediaType, errors.ErrUnsupportedMediaType
+	}
+}
+
+func writeImportReport(w io.Writer, rowErrors []*service.ImportRowError) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"row", "sku", "error"})
+	for _, rowError := range rowErrors {
+		_ = writer.Write([]string{strconv.Itoa(rowError.Row), rowError.SKU, rowError.Error})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// handleReplaceProduct replaces the whole product. Fields left out of
+// the body are cleared.
+func (api *API) handleReplaceProduct(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleReplaceProduct")
+	defer span.End()
+
+	id := productID(c)
+
+	var replaceRequest service.ReplaceProductRequest
+	if code, err := bindJSON(c, &replaceRequest); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	versions, code, err := parseIfMatch(c.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		responseWithPrecondition(c, code, nil, err)
+		return nil
+	}
+	replaceRequest.Versions = versions
+
+	result, code, err := api.service.ReplaceProduct(ctx, id, replaceRequest)
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
+
+	responseWithPrecondition(c, code, result, err)
+	return nil
+}
+
+const (
+	mimeMergePatchJSON = "application/merge-patch+json"
+	mimeJSONPatchJSON  = "application/json-patch+json"
+)
+
+// handlePatchProduct updates the fields a JSON Merge Patch or JSON Patch
+// body touches, picked by Content-Type.
+func (api *API) handlePatchProduct(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handlePatchProduct")
+	defer span.End()
+
+	id := productID(c)
+
+	var patchType string
+	switch {
+	case hasContentType(c, mimeMergePatchJSON):
+		patchType = service.PatchMerge
+	case hasContentType(c, mimeJSONPatchJSON):
+		patchType = service.PatchJSON
+	default:
+		c.Set(fiber.HeaderAcceptPatch, mimeMergePatchJSON+", "+mimeJSONPatchJSON)
+		utils.ResponseWithJSON(c, fiber.StatusUnsupportedMediaType, nil, errors.ErrUnsupportedMediaType, nil)
+		return nil
+	}
+
+	if code, err := checkBodySize(c); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	versions, code, err := parseIfMatch(c.Get(fiber.HeaderIfMatch))
+	if err != nil {
+		responseWithPrecondition(c, code, nil, err)
+		return nil
+	}
+
+	result, code, err := api.service.PatchProduct(ctx, id, service.PatchProductRequest{
+		Type:     patchType,
+		Patch:    c.Body(),
+		Versions: versions,
+	})
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
+
+	responseWithPrecondition(c, code, result, err)
+	return nil
+}
+
 func (api *API) handleDeleteProduct(c *fiber.Ctx) error {
 	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleDeleteProduct")
 	defer span.End()
 
-	id := c.Params("id")
+	id := productID(c)
 
 	code, err := api.service.DeleteProduct(ctx, id)
 	if err != nil {
@@ -96,4 +383,181 @@ func (api *API) handleDeleteProduct(c *fiber.Ctx) error {
 	}
 
 	return c.Status(code).JSON(fiber.Map{"message": "Product deleted successfully"})
-}
\ No newline at end of file
+}
+
+func (api *API) handleRestoreProduct(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleRestoreProduct")
+	defer span.End()
+
+	id := productID(c)
+
+	result, code, err := api.service.RestoreProduct(ctx, id)
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handlePurgeProducts(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handlePurgeProducts")
+	defer span.End()
+
+	result, code, err := api.service.PurgeProducts(ctx)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleAdjustStock(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleAdjustStock")
+	defer span.End()
+
+	id := productID(c)
+
+	var request service.AdjustStockRequest
+	if code, err := bindJSON(c, &request); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	result, code, err := api.service.AdjustStock(ctx, id, request)
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleTransferStock(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleTransferStock")
+	defer span.End()
+
+	id := productID(c)
+
+	var request service.TransferStockRequest
+	if code, err := bindJSON(c, &request); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	result, code, err := api.service.TransferStock(ctx, id, request)
+	if result != nil {
+		c.Set(fiber.HeaderETag, etag(result.Version))
+	}
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleGetStockMovements(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleGetStockMovements")
+	defer span.End()
+
+	id := productID(c)
+
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		limit = 5
+	}
+
+	result, pagination, code, err := api.service.GetStockMovements(ctx, id, service.GetStockMovementsRequest{
+		Page:  page,
+		Limit: limit,
+	})
+
+	utils.ResponseWithJSON(c, code, result, err, pagination)
+	return nil
+}
+
+// etag to format product version as a strong entity tag.
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// responseWithPrecondition to write the response of a conditional request.
+// Failed preconditions and conflicts get the HTTP status too, since If-Match
+// is HTTP level and clients and proxies look at the status line for it.
+func responseWithPrecondition(c *fiber.Ctx, code int, data interface{}, err error) {
+	switch code {
+	case fiber.StatusPreconditionFailed, fiber.StatusPreconditionRequired, fiber.StatusConflict:
+		c.Status(code)
+	}
+	utils.ResponseWithJSON(c, code, data, err, nil)
+}
+
+// parseIfMatch to get the product versions from If-Match header, any of
+// which may match. Empty header and "*" return no versions, meaning no
+// precondition. Weak tags are taken as their version too, since the tag
+// is the version whatever the encoding and compressing proxies weaken it.
+// Tags this service never issues can't match, so if no tag is a version
+// the precondition already failed.
+func parseIfMatch(header string) ([]int, int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, fiber.StatusOK, nil
+	}
+
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, fiber.StatusBadRequest, errors.ErrInvalidIfMatch
+		}
+
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil {
+			versions = append(versions, version)
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, fiber.StatusPreconditionFailed, errors.ErrProductVersionMismatch
+	}
+
+	return versions, fiber.StatusOK, nil
+}
+
+// checkQuery to reject query parameters not in allowed.
+func checkQuery(c *fiber.Ctx, allowed []string) error {
+	for key := range c.Queries() {
+		if !slices.Contains(allowed, key) {
+			return errors.ErrUnknownQueryParam(key)
+		}
+	}
+	return nil
+}
+
+// queryInt to parse an optional int query parameter.
+func queryInt(c *fiber.Ctx, key string) (*int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, errors.ErrInvalidQueryParam(key)
+	}
+	return &i, nil
+}
+
+// queryBool to parse an optional bool query parameter.
+func queryBool(c *fiber.Ctx, key string) (*bool, error) {
+	value := c.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, errors.ErrInvalidQueryParam(key)
+	}
+	return &b, nil
+}
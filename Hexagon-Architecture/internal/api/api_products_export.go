@@ -0,0 +1,234 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/internal/utils"
+	"hexagon-architecture/pkg/xlsx"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Export formats.
+const (
+	exportCSV    = "csv"
+	exportNDJSON = "ndjson"
+	exportXLSX   = "xlsx"
+)
+
+const (
+	mimeNDJSON = "application/x-ndjson"
+	mimeXLSX   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// exportColumns is the header of csv and xlsx exports.
+var exportColumns = []string{
+	"id", "sku", "name_product", "description", "category", "tags", "price",
+	"currency", "stock", "reserved", "available", "version", "created_at",
+}
+
+// productsEncoder writes exported products in one format.
+type productsEncoder interface {
+	Encode(product *service.Products) error
+	Close() error
+}
+
+// handleExportProducts streams every product matching the same filters
+// as GET /products. Errors before the first byte are JSON like everywhere
+// else; after that the response is already 200 so they can only cut the
+// file short.
+func (api *API) handleExportProducts(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleExportProducts")
+	defer span.End()
+
+	if err := checkQuery(c, exportProductsQuery); err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	format := strings.ToLower(c.Query("format", exportCSV))
+	contentType, ok := map[string]string{
+		exportCSV:    mimeTextCSV,
+		exportNDJSON: mimeNDJSON,
+		exportXLSX:   mimeXLSX,
+	}[format]
+	if !ok {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, errors.ErrInvalidQueryParam("format"), nil)
+		return nil
+	}
+
+	request, err := parseProductsFilter(c)
+	if err != nil {
+		utils.ResponseWithJSON(c, fiber.StatusBadRequest, nil, err, nil)
+		return nil
+	}
+
+	products, code, err := api.service.ExportProducts(ctx, request)
+	if err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	// The handler returns before the body is written, so the stream gets
+	// its own context rather than one tied to this span.
+	ctx = context.WithoutCancel(ctx)
+
+	// The server write timeout covers the whole response, which a large
+	// export can outlast, so it is lifted once streaming starts.
+	conn := c.Context().Conn()
+
+	c.Attachment("products." + format)
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer products.Close(ctx)
+
+		_ = conn.SetWriteDeadline(time.Time{})
+
+		if err := writeExport(ctx, w, format, products); err != nil {
+			slog.ErrorContext(ctx, "failed to export products",
+				slog.String("format", format),
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+
+	return nil
+}
+
+func writeExport(ctx context.Context, w *bufio.Writer, format string, products *service.ProductsIterator) error {
+	var encoder productsEncoder
+	var err error
+	switch format {
+	case exportNDJSON:
+		encoder = &ndjsonEncoder{encoder: json.NewEncoder(w)}
+	case exportXLSX:
+		encoder, err = newXLSXEncoder(w)
+	default:
+		encoder, err = newCSVEncoder(w)
+	}
+	if err != nil {
+		return err
+	}
+
+	for products.Next(ctx) {
+		if err := encoder.Encode(products.Product()); err != nil {
+			return err
+		}
+	}
+	if err := products.Err(); err != nil {
+		return err
+	}
+
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(product *service.Products) error {
+	return e.encoder.Encode(product)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+type csvEncoder struct {
+	writer *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return &csvEncoder{writer: writer}, nil
+}
+
+func (e *csvEncoder) Encode(p *service.Products) error {
+	return e.writer.Write([]string{
+		p.ID,
+		csvText(p.SKU),
+		csvText(p.Name),
+		csvText(p.Description),
+		csvText(p.Category),
+		csvText(strings.Join(p.Tags, "|")),
+		strconv.FormatInt(p.Price, 10),
+		p.Currency,
+		strconv.Itoa(p.Stock),
+		strconv.Itoa(p.Reserved),
+		strconv.Itoa(p.Available),
+		strconv.Itoa(p.Version),
+		p.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// csvText to stop spreadsheets from reading user text as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type xlsxEncoder struct {
+	writer *xlsx.Writer
+}
+
+func newXLSXEncoder(w io.Writer) (*xlsxEncoder, error) {
+	writer, err := xlsx.NewWriter(w, "products")
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]interface{}, len(exportColumns))
+	for i, column := range exportColumns {
+		header[i] = column
+	}
+	if err := writer.WriteRow(header...); err != nil {
+		return nil, err
+	}
+
+	return &xlsxEncoder{writer: writer}, nil
+}
+
+func (e *xlsxEncoder) Encode(p *service.Products) error {
+	return e.writer.WriteRow(
+		p.ID,
+		p.SKU,
+		p.Name,
+		p.Description,
+		p.Category,
+		strings.Join(p.Tags, "|"),
+		p.Price,
+		p.Currency,
+		p.Stock,
+		p.Reserved,
+		p.Available,
+		p.Version,
+		p.CreatedAt.Format(time.RFC3339),
+	)
+}
+
+func (e *xlsxEncoder) Close() error {
+	return e.writer.Close()
+}
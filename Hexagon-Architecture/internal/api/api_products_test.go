@@ -0,0 +1,141 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"hexagon-architecture/internal/errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	tests := map[string]struct {
+		header           string
+		expectedVersions []int
+		expectedCode     int
+		expectedErr      error
+	}{
+		"empty": {
+			header:       "",
+			expectedCode: http.StatusOK,
+		},
+		"any": {
+			header:       " * ",
+			expectedCode: http.StatusOK,
+		},
+		"one": {
+			header:           `"3"`,
+			expectedVersions: []int{3},
+			expectedCode:     http.StatusOK,
+		},
+		"weak": {
+			header:           `W/"3"`,
+			expectedVersions: []int{3},
+			expectedCode:     http.StatusOK,
+		},
+		"list": {
+			header:           `"1", W/"2" ,"3"`,
+			expectedVersions: []int{1, 2, 3},
+			expectedCode:     http.StatusOK,
+		},
+		"list-with-foreign-tag": {
+			header:           `"abc", "2"`,
+			expectedVersions: []int{2},
+			expectedCode:     http.StatusOK,
+		},
+		"foreign-tags": {
+			header:       `"abc", W/"xyz"`,
+			expectedCode: http.StatusPreconditionFailed,
+			expectedErr:  errors.ErrProductVersionMismatch,
+		},
+		"unquoted": {
+			header:       `3`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidIfMatch,
+		},
+		"unquoted-in-list": {
+			header:       `"1", 2`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidIfMatch,
+		},
+		"unterminated": {
+			header:       `"3`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidIfMatch,
+		},
+		"empty-tag": {
+			header:       `"1",`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidIfMatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			versions, code, err := parseIfMatch(test.header)
+			assert.Equal(t, test.expectedVersions, versions)
+			assert.Equal(t, test.expectedCode, code)
+			assert.Equal(t, test.expectedErr, err)
+		})
+	}
+}
+
+func TestReplaceProductPrecondition(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	resp, created := doRequest(t, app, fiber.MethodPost, "/product", `{"sku":"APL-1","name_product":"Apple","stock":1}`, nil)
+	assert.Equal(t, http.StatusOK, created.Status)
+	assert.Equal(t, `"1"`, resp.Header.Get(fiber.HeaderETag))
+	id := created.Data.(map[string]interface{})["id"].(string)
+
+	body := `{"sku":"APL-1","name_product":"Green Apple","stock":1}`
+	tests := []struct {
+		name         string
+		ifMatch      string
+		expectedCode int
+		expectedETag string
+	}{
+		{name: "malformed", ifMatch: `1`, expectedCode: http.StatusBadRequest},
+		{name: "foreign-tag", ifMatch: `"abc"`, expectedCode: http.StatusPreconditionFailed},
+		{name: "stale", ifMatch: `"2"`, expectedCode: http.StatusPreconditionFailed},
+		{name: "ok", ifMatch: `"0", W/"1"`, expectedCode: http.StatusOK, expectedETag: `"2"`},
+		{name: "replaced", ifMatch: `"1"`, expectedCode: http.StatusPreconditionFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, response := doRequest(t, app, fiber.MethodPut, "/product/"+id, body, map[string]string{
+				fiber.HeaderIfMatch: test.ifMatch,
+			})
+			assert.Equal(t, test.expectedCode, response.Status)
+			if test.expectedCode == http.StatusPreconditionFailed {
+				assert.Equal(t, test.expectedCode, resp.StatusCode)
+			}
+			assert.Equal(t, test.expectedETag, resp.Header.Get(fiber.HeaderETag))
+		})
+	}
+}
+
+func TestPatchProductPrecondition(t *testing.T) {
+	app, _ := newTestApp(t)
+
+	_, created := doRequest(t, app, fiber.MethodPost, "/product", `{"sku":"APL-1","name_product":"Apple","stock":1}`, nil)
+	id := created.Data.(map[string]interface{})["id"].(string)
+
+	resp, response := doRequest(t, app, fiber.MethodPatch, "/product/"+id, `{"name_product":"Green Apple"}`, map[string]string{
+		fiber.HeaderContentType: "application/merge-patch+json",
+		fiber.HeaderIfMatch:     `"5"`,
+	})
+	assert.Equal(t, http.StatusPreconditionFailed, response.Status)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	resp, response = doRequest(t, app, fiber.MethodPatch, "/product/"+id, `{"name_product":"Green Apple"}`, map[string]string{
+		fiber.HeaderContentType: "application/merge-patch+json",
+		fiber.HeaderIfMatch:     `"1"`,
+	})
+	assert.Equal(t, http.StatusOK, response.Status)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get(fiber.HeaderETag))
+}
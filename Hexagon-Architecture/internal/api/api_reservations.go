@@ -0,0 +1,63 @@
+package api
+
+import (
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (api *API) handleReserveStock(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleReserveStock")
+	defer span.End()
+
+	id := productID(c)
+
+	var request service.ReserveStockRequest
+	if code, err := bindJSON(c, &request); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	result, code, err := api.service.ReserveStock(ctx, id, request)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleGetReservation(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleGetReservation")
+	defer span.End()
+
+	id := c.Params("id")
+
+	result, code, err := api.service.GetReservation(ctx, id)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleConfirmReservation(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleConfirmReservation")
+	defer span.End()
+
+	id := c.Params("id")
+
+	result, code, err := api.service.ConfirmReservation(ctx, id)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleReleaseReservation(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleReleaseReservation")
+	defer span.End()
+
+	id := c.Params("id")
+
+	result, code, err := api.service.ReleaseReservation(ctx, id)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	idempotencyKeysMemory "hexagon-architecture/internal/domain/idempotencykeys/repository/memory"
+	productsMemory "hexagon-architecture/internal/domain/products/repository/memory"
+	reservationsMemory "hexagon-architecture/internal/domain/reservations/repository/memory"
+	stockMovementsMemory "hexagon-architecture/internal/domain/stockmovements/repository/memory"
+	warehousesMemory "hexagon-architecture/internal/domain/warehouses/repository/memory"
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/internal/utils"
+	"hexagon-architecture/pkg/health"
+	"hexagon-architecture/pkg/transaction"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// newTestApp returns the api on fresh memory repositories, registered on
+// a fiber app recovering panics like the server does.
+func newTestApp(t *testing.T) (*fiber.App, *API) {
+	t.Helper()
+
+	products := productsMemory.New()
+	stockMovements := stockMovementsMemory.New()
+	reservations := reservationsMemory.New()
+	s := service.New(
+		products,
+		stockMovements,
+		reservations,
+		warehousesMemory.New(),
+		idempotencyKeysMemory.New(),
+		transaction.NewMemory(products, stockMovements, reservations),
+		service.Config{IdempotencyKeyTTL: time.Hour},
+	)
+	api := New(s, health.New(health.Config{Timeout: time.Second}), "test", Auth{})
+
+	app := fiber.New()
+	app.Use(recover.New())
+	api.Register(app)
+	return app, api
+}
+
+// newRequest returns a request with a JSON body, unless headers set
+// another content type.
+func newRequest(t *testing.T, method, target, body string, headers map[string]string) *http.Request {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return req
+}
+
+// doRequest sends a request made by newRequest to app and returns the
+// response with its decoded body.
+func doRequest(t *testing.T, app *fiber.App, method, target, body string, headers map[string]string) (*http.Response, utils.Response) {
+	t.Helper()
+
+	resp, err := app.Test(newRequest(t, method, target, body, headers), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response utils.Response
+	if err := json.Unmarshal(raw, &response); err != nil {
+		t.Fatalf("%v: %s", err, raw)
+	}
+	return resp, response
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (api *API) handleGetWarehouses(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleGetWarehouses")
+	defer span.End()
+
+	result, code, err := api.service.GetWarehouses(ctx)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
+
+func (api *API) handleCreateWarehouse(c *fiber.Ctx) error {
+	ctx, span := infrastructure.Tracer().Start(c.UserContext(), "ping:handleCreateWarehouse")
+	defer span.End()
+
+	var request service.CreateWarehouseRequest
+	if code, err := bindJSON(c, &request); err != nil {
+		utils.ResponseWithJSON(c, code, nil, err, nil)
+		return nil
+	}
+
+	result, code, err := api.service.CreateWarehouse(ctx, request)
+
+	utils.ResponseWithJSON(c, code, result, err, nil)
+	return nil
+}
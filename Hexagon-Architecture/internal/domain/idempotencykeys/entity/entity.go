@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// IdempotencyKeys is a request made with an Idempotency-Key header and,
+// once it finishes, the response to replay on retries.
+type IdempotencyKeys struct {
+	Key         string
+	RequestHash string
+	Completed   bool
+	Status      int
+	ContentType string
+	ETag        string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
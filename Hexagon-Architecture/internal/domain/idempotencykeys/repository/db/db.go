@@ -0,0 +1,17 @@
+package db
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// DB is contains functions for idempotency keys db.
+type DB struct {
+	db              *mongo.Database
+	idempotencyKeys string
+}
+
+// New to create new idempotency keys db.
+func New(db *mongo.Database, idempotencyKeys string) *DB {
+	return &DB{
+		db:              db,
+		idempotencyKeys: idempotencyKeys,
+	}
+}
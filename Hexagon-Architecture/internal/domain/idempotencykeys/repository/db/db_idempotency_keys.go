@@ -0,0 +1,121 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/idempotencykeys/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// IdempotencyKeys is model database for idempotency keys.
+type IdempotencyKeys struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Completed   bool      `bson:"completed"`
+	Status      int       `bson:"status,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	ETag        string    `bson:"etag,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+func (key *IdempotencyKeys) toEntity() *entity.IdempotencyKeys {
+	return &entity.IdempotencyKeys{
+		Key:         key.Key,
+		RequestHash: key.RequestHash,
+		Completed:   key.Completed,
+		Status:      key.Status,
+		ContentType: key.ContentType,
+		ETag:        key.ETag,
+		Body:        key.Body,
+		CreatedAt:   key.CreatedAt,
+		ExpiresAt:   key.ExpiresAt,
+	}
+}
+
+// EnsureIndexes to let mongo remove expired keys.
+func (db *DB) EnsureIndexes(ctx context.Context) error {
+	_, err := db.db.Collection(db.idempotencyKeys).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (db *DB) CreateKey(ctx context.Context, key entity.IdempotencyKeys) (_ *entity.IdempotencyKeys, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CreateKey")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateKey", time.Now(), &code)
+
+	data := IdempotencyKeys{
+		Key:         key.Key,
+		RequestHash: key.RequestHash,
+		CreatedAt:   key.CreatedAt,
+		ExpiresAt:   key.ExpiresAt,
+	}
+
+	// The TTL monitor only runs every minute, so an expired key may still
+	// be there and is replaced as if it were gone.
+	filter := bson.M{
+		"_id":        key.Key,
+		"expires_at": bson.M{"$lte": key.CreatedAt},
+	}
+
+	_, err := db.db.Collection(db.idempotencyKeys).ReplaceOne(ctx, filter, data, options.Replace().SetUpsert(true))
+	if err != nil {
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, http.StatusInternalServerError, errors.ErrInternalDB
+		}
+
+		var existing IdempotencyKeys
+		if err := db.db.Collection(db.idempotencyKeys).FindOne(ctx, bson.M{"_id": key.Key}).Decode(&existing); err != nil {
+			return nil, http.StatusInternalServerError, errors.ErrInternalDB
+		}
+		return existing.toEntity(), http.StatusOK, nil
+	}
+
+	return nil, http.StatusOK, nil
+}
+
+func (db *DB) CompleteKey(ctx context.Context, key string, response entity.IdempotencyKeys) (code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CompleteKey")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CompleteKey", time.Now(), &code)
+
+	update := bson.M{"$set": bson.M{
+		"completed":    true,
+		"status":       response.Status,
+		"content_type": response.ContentType,
+		"etag":         response.ETag,
+		"body":         response.Body,
+	}}
+
+	res, err := db.db.Collection(db.idempotencyKeys).UpdateOne(ctx, bson.M{"_id": key}, update)
+	if err != nil {
+		return http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	if res.MatchedCount == 0 {
+		return http.StatusNotFound, errors.ErrNotFoundIdempotencyKey
+	}
+
+	return http.StatusOK, nil
+}
+
+func (db *DB) DeleteKey(ctx context.Context, key string) (code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:DeleteKey")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "DeleteKey", time.Now(), &code)
+
+	if _, err := db.db.Collection(db.idempotencyKeys).DeleteOne(ctx, bson.M{"_id": key}); err != nil {
+		return http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return http.StatusOK, nil
+}
@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"sync"
+
+	"hexagon-architecture/internal/domain/idempotencykeys/entity"
+)
+
+// Memory is contains functions for in-memory idempotency keys storage.
+type Memory struct {
+	mu   sync.Mutex
+	keys map[string]entity.IdempotencyKeys
+}
+
+// New to create new in-memory idempotency keys storage.
+func New() *Memory {
+	return &Memory{
+		keys: make(map[string]entity.IdempotencyKeys),
+	}
+}
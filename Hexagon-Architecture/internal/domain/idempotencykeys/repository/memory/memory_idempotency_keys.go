@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/idempotencykeys/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+)
+
+func (m *Memory) CreateKey(ctx context.Context, key entity.IdempotencyKeys) (_ *entity.IdempotencyKeys, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CreateKey")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateKey", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.keys[key.Key]; ok && existing.ExpiresAt.After(key.CreatedAt) {
+		return &existing, http.StatusOK, nil
+	}
+
+	m.keys[key.Key] = key
+
+	return nil, http.StatusOK, nil
+}
+
+func (m *Memory) CompleteKey(ctx context.Context, key string, response entity.IdempotencyKeys) (code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CompleteKey")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CompleteKey", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.keys[key]
+	if !ok {
+		return http.StatusNotFound, errors.ErrNotFoundIdempotencyKey
+	}
+
+	existing.Completed = true
+	existing.Status = response.Status
+	existing.ContentType = response.ContentType
+	existing.ETag = response.ETag
+	existing.Body = append([]byte(nil), response.Body...)
+	m.keys[key] = existing
+
+	return http.StatusOK, nil
+}
+
+func (m *Memory) DeleteKey(ctx context.Context, key string) (code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:DeleteKey")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "DeleteKey", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.keys, key)
+
+	return http.StatusOK, nil
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/idempotencykeys/entity"
+)
+
+// Repository contains functions for idempotency keys domain.
+type Repository interface {
+	// CreateKey saves key unless it already exists and isn't expired, in
+	// which case the existing key is returned instead.
+	CreateKey(ctx context.Context, key entity.IdempotencyKeys) (*entity.IdempotencyKeys, int, error)
+	CompleteKey(ctx context.Context, key string, response entity.IdempotencyKeys) (int, error)
+	DeleteKey(ctx context.Context, key string) (int, error)
+}
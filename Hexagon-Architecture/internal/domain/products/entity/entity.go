@@ -1,18 +1,329 @@
 package entity
 
+import (
+	"bytes"
+	"encoding/hex"
+	"hexagon-architecture/internal/errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ProductID is the id of a product, 12 bytes in lowercase hex. Use
+// ParseProductID to get one from user input.
+type ProductID string
+
+// productIDSize is the size of a product id in bytes.
+const productIDSize = 12
+
+// ParseProductID to check str is a valid product id. The all zero id is
+// never assigned to a product, so it is rejected too.
+func ParseProductID(str string) (ProductID, error) {
+	b, err := hex.DecodeString(str)
+	if err != nil || len(b) != productIDSize || bytes.Count(b, []byte{0}) == productIDSize {
+		return "", errors.ErrInvalidProductID
+	}
+	return ProductID(strings.ToLower(str)), nil
+}
+
+func (id ProductID) String() string {
+	return string(id)
+}
+
+// Product sort field list.
+const (
+	SortName      = "name"
+	SortStock     = "stock"
+	SortCreatedAt = "created_at"
+)
+
+// Name match mode list.
+const (
+	MatchContains = "contains"
+	MatchPrefix   = "prefix"
+	MatchExact    = "exact"
+	MatchRegex    = "regex"
+)
+
 type Products struct {
-	ID    string
-	Name  string
-	Stock int
+	ID          string
+	SKU         string
+	Name        string
+	Description string
+	Category    string
+	Tags        []string
+	Price       int64
+	Currency    string
+	Stock       int
+	Reserved    int
+	Locations   []Locations
+	Version     int
+	CreatedAt   time.Time
+	DeletedAt   *time.Time
+	DeletedBy   string
+}
+
+// Locations is stock of a product kept in a warehouse.
+type Locations struct {
+	WarehouseID string
+	Stock       int
+}
+
+// Allocated is product stock assigned to warehouses.
+func (p Products) Allocated() int {
+	var allocated int
+	for _, location := range p.Locations {
+		allocated += location.Stock
+	}
+	return allocated
+}
+
+// Unallocated is product stock not assigned to any warehouse.
+func (p Products) Unallocated() int {
+	return p.Stock - p.Allocated()
+}
+
+// LocationStock is product stock kept in the given warehouse.
+func (p Products) LocationStock(warehouseID string) int {
+	for _, location := range p.Locations {
+		if location.WarehouseID == warehouseID {
+			return location.Stock
+		}
+	}
+	return 0
+}
+
+// Apply returns the product with fields set in update changed.
+func (p Products) Apply(update UpdateProductsRequest) Products {
+	if update.Name != nil {
+		p.Name = *update.Name
+	}
+	if update.SKU != nil {
+		p.SKU = *update.SKU
+	}
+	if update.Description != nil {
+		p.Description = *update.Description
+	}
+	if update.Category != nil {
+		p.Category = *update.Category
+	}
+	if update.Tags != nil {
+		p.Tags = update.Tags
+	}
+	if update.Price != nil {
+		p.Price = *update.Price
+	}
+	if update.Currency != nil {
+		p.Currency = *update.Currency
+	}
+	if update.Stock != nil {
+		p.Stock = *update.Stock
+	}
+	return p
+}
+
+// DrawStock returns product locations after taking quantity out of the
+// product stock. Unallocated stock is taken first, then the warehouses in
+// order, which a single database update can do too.
+func (p Products) DrawStock(quantity int) []Locations {
+	locations := make([]Locations, len(p.Locations))
+	copy(locations, p.Locations)
+
+	quantity -= p.Unallocated()
+	for i := range locations {
+		if quantity <= 0 {
+			break
+		}
+
+		taken := quantity
+		if taken > locations[i].Stock {
+			taken = locations[i].Stock
+		}
+		locations[i].Stock -= taken
+		quantity -= taken
+	}
+
+	return locations
+}
+
+// CommitReserved returns the product after quantity of its reserved stock
+// left it, drawn like DrawStock, and the stock taken out of each warehouse
+// for it. Warehouses nothing was taken from are left out.
+func (p Products) CommitReserved(quantity int) (Products, []Locations) {
+	committed := p
+	if len(p.Locations) > 0 {
+		committed.Locations = p.DrawStock(quantity)
+	}
+	committed.Stock -= quantity
+	committed.Reserved -= quantity
+	committed.Version++
+
+	var taken []Locations
+	for i, location := range committed.Locations {
+		if stock := p.Locations[i].Stock - location.Stock; stock > 0 {
+			taken = append(taken, Locations{WarehouseID: location.WarehouseID, Stock: stock})
+		}
+	}
+
+	return committed, taken
 }
 
 type GetProductsRequest struct {
-	Page  int
-	Limit int
-	Name  string
+	Page     int
+	Limit    int
+	Name     string
+	Match    string
+	SKU      string
+	Category string
+	Tag      string
+	Currency string
+	StockLT  *int
+	StockLTE *int
+	StockGT  *int
+	StockGTE *int
+	InStock  *bool
+	Sort     []SortField
+}
+
+// SortField is a product field to sort by.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// SortString is sort fields in the sort query format, e.g. "name,-stock".
+func SortString(fields []SortField) string {
+	str := make([]string, len(fields))
+	for i, field := range fields {
+		str[i] = field.Field
+		if field.Desc {
+			str[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(str, ",")
+}
+
+// NamePattern is the case-insensitive regex pattern name is searched with.
+// Name is taken literally unless match is regex.
+func (r GetProductsRequest) NamePattern() string {
+	switch r.Match {
+	case MatchPrefix:
+		return "^" + regexp.QuoteMeta(r.Name)
+	case MatchExact:
+		return "^" + regexp.QuoteMeta(r.Name) + "$"
+	case MatchRegex:
+		return r.Name
+	default:
+		return regexp.QuoteMeta(r.Name)
+	}
+}
+
+// Stock bucket list for search facets.
+const (
+	StockBucketOut = "out_of_stock"
+	StockBucketLow = "low"
+	StockBucketIn  = "in_stock"
+)
+
+// LowStockThreshold is stock below which a product is low on stock.
+const LowStockThreshold = 10
+
+// StockBucket is the search facet bucket of stock.
+func StockBucket(stock int) string {
+	switch {
+	case stock <= 0:
+		return StockBucketOut
+	case stock < LowStockThreshold:
+		return StockBucketLow
+	default:
+		return StockBucketIn
+	}
+}
+
+// Suggestion is a product name matching a typed prefix.
+type Suggestion struct {
+	ID   string
+	Name string
+}
+
+type SearchProductsRequest struct {
+	Query    string
+	Category string
+	Limit    int
+}
+
+// SearchResult is the best matching products and facet counts of all
+// matching products.
+type SearchResult struct {
+	Hits   []SearchHit
+	Facets SearchFacets
+}
+
+type SearchHit struct {
+	Product Products
+	Score   float64
+}
+
+type SearchFacets struct {
+	Category []FacetCount
+	Stock    []FacetCount
+}
+
+type FacetCount struct {
+	Value string
+	Count int
 }
 
 type UpdateProductsRequest struct {
-	Name  string
-	Stock *int
-}
\ No newline at end of file
+	Name        *string
+	SKU         *string
+	Description *string
+	Category    *string
+	Tags        []string
+	Price       *int64
+	Currency    *string
+	Stock       *int
+	Version     *int
+}
+
+// Bulk operation list.
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkOperation is one item of a bulk write. Product is used by create,
+// ID by update and delete, Update by update and DeletedBy by delete.
+type BulkOperation struct {
+	Op        string
+	ID        ProductID
+	Product   Products
+	Update    UpdateProductsRequest
+	DeletedBy string
+}
+
+// BulkResult is the outcome of the bulk operation at Index. Product is the
+// product after create or update and StockDelta how much its stock changed.
+type BulkResult struct {
+	Index      int
+	ID         string
+	Product    *Products
+	StockDelta int
+	Code       int
+	Err        error
+}
+
+type AdjustStockRequest struct {
+	Delta       int
+	Reason      string
+	WarehouseID string
+}
+
+// TransferStockRequest moves stock between warehouses.
+// Empty warehouse id means unallocated stock.
+type TransferStockRequest struct {
+	FromWarehouseID string
+	ToWarehouseID   string
+	Quantity        int
+}
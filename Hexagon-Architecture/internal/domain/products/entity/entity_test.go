@@ -0,0 +1,55 @@
+package entity_test
+
+import (
+	"testing"
+
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProductID(t *testing.T) {
+	for _, str := range []string{"", "invalid", "000000000000000000000000", "65a1b2c3d4e5f6a7b8c9d0e"} {
+		_, err := entity.ParseProductID(str)
+		assert.Equal(t, errors.ErrInvalidProductID, err, str)
+	}
+
+	id, err := entity.ParseProductID("65a1b2c3d4e5f6a7b8c9d0e1")
+	assert.NoError(t, err)
+	assert.Equal(t, "65a1b2c3d4e5f6a7b8c9d0e1", id.String())
+	assert.Equal(t, id, entity.ProductID(id.String()))
+}
+
+func TestCommitReserved(t *testing.T) {
+	product := entity.Products{
+		Stock:    10,
+		Reserved: 7,
+		Version:  3,
+		Locations: []entity.Locations{
+			{WarehouseID: "north", Stock: 3},
+			{WarehouseID: "east", Stock: 0},
+			{WarehouseID: "south", Stock: 4},
+		},
+	}
+
+	committed, taken := product.CommitReserved(7)
+	assert.Equal(t, 3, committed.Stock)
+	assert.Equal(t, 0, committed.Reserved)
+	assert.Equal(t, 4, committed.Version)
+	assert.Equal(t, []entity.Locations{
+		{WarehouseID: "north", Stock: 0},
+		{WarehouseID: "east", Stock: 0},
+		{WarehouseID: "south", Stock: 3},
+	}, committed.Locations)
+	assert.Equal(t, []entity.Locations{
+		{WarehouseID: "north", Stock: 3},
+		{WarehouseID: "south", Stock: 1},
+	}, taken, "unallocated stock goes first")
+	assert.Equal(t, 3, product.Locations[0].Stock, "product is left as it was")
+
+	committed, taken = entity.Products{Stock: 5, Reserved: 2}.CommitReserved(2)
+	assert.Equal(t, 3, committed.Stock)
+	assert.Nil(t, committed.Locations)
+	assert.Nil(t, taken)
+}
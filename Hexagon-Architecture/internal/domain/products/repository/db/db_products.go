@@ -2,12 +2,13 @@ package db
 
 import (
 	"context"
+	"encoding/base64"
 	"hexagon-architecture/internal/domain/products/entity"
 	"hexagon-architecture/internal/errors"
 	"hexagon-architecture/internal/infrastructure"
 	"hexagon-architecture/internal/utils"
-	"log"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -19,23 +20,107 @@ import (
 
 // Company is model database for company
 type Products struct {
-	ID     primitive.ObjectID `bson:"_id,omitempty"`
-	Name   string             `bson:"name_product"`
-	Stock  int             `bson:"stock"`
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	SKU         string             `bson:"sku,omitempty"`
+	Name        string             `bson:"name_product"`
+	NameLower   string             `bson:"name_lower"`
+	Description string             `bson:"description,omitempty"`
+	Category    string             `bson:"category,omitempty"`
+	Tags        []string           `bson:"tags,omitempty"`
+	Price       int64              `bson:"price"`
+	Currency    string             `bson:"currency,omitempty"`
+	Stock       int                `bson:"stock"`
+	Reserved    int                `bson:"reserved"`
+	Locations   []Locations        `bson:"locations,omitempty"`
+	Version     int                `bson:"version"`
+	CreatedAt   time.Time          `bson:"created_at,omitempty"`
+	DeletedAt   *time.Time         `bson:"deleted_at,omitempty"`
+	DeletedBy   string             `bson:"deleted_by,omitempty"`
+}
+
+// Locations is model database for product stock in a warehouse.
+type Locations struct {
+	WarehouseID primitive.ObjectID `bson:"warehouse_id"`
+	Stock       int                `bson:"stock"`
+}
+
+func fromLocations(l []entity.Locations) []Locations {
+	locations := make([]Locations, len(l))
+	for i, location := range l {
+		warehouseID, _ := primitive.ObjectIDFromHex(location.WarehouseID)
+		locations[i] = Locations{
+			WarehouseID: warehouseID,
+			Stock:       location.Stock,
+		}
+	}
+	return locations
+}
+
+func toLocations(l []Locations) []entity.Locations {
+	locations := make([]entity.Locations, len(l))
+	for i, location := range l {
+		locations[i] = entity.Locations{
+			WarehouseID: location.WarehouseID.Hex(),
+			Stock:       location.Stock,
+		}
+	}
+	return locations
+}
+
+// objectID is the ObjectID of a product id. Ids are parsed where they
+// come in, a malformed one gives the zero ObjectID, matching no product.
+func objectID(id entity.ProductID) primitive.ObjectID {
+	_id, _ := primitive.ObjectIDFromHex(id.String())
+	return _id
 }
 
 func (db *DB) fromEntity(products entity.Products) Products {
+	// Empty id of a new product stays zero and is left out on insert.
+	id, _ := primitive.ObjectIDFromHex(products.ID)
+
 	return Products{
-		Name:   products.Name,
-		Stock: products.Stock,
+		ID:          id,
+		SKU:         products.SKU,
+		Name:        products.Name,
+		NameLower:   strings.ToLower(products.Name),
+		Description: products.Description,
+		Category:    products.Category,
+		Tags:        products.Tags,
+		Price:       products.Price,
+		Currency:    products.Currency,
+		Stock:       products.Stock,
+		Reserved:    products.Reserved,
+		Locations:   fromLocations(products.Locations),
+		Version:     products.Version,
+		CreatedAt:   products.CreatedAt,
+		DeletedAt:   products.DeletedAt,
+		DeletedBy:   products.DeletedBy,
 	}
 }
 
 func (products *Products) toEntity() *entity.Products {
+	// Products created before created_at was stored still have it in their id.
+	createdAt := products.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = products.ID.Timestamp()
+	}
+
 	return &entity.Products{
-		ID:   products.ID.Hex(),
-		Name:   products.Name,
-		Stock: products.Stock,
+		ID:          products.ID.Hex(),
+		SKU:         products.SKU,
+		Name:        products.Name,
+		Description: products.Description,
+		Category:    products.Category,
+		Tags:        products.Tags,
+		Price:       products.Price,
+		Currency:    products.Currency,
+		Stock:       products.Stock,
+		Reserved:    products.Reserved,
+		Locations:   toLocations(products.Locations),
+		Version:     products.Version,
+		CreatedAt:   createdAt,
+		DeletedAt:   products.DeletedAt,
+		DeletedBy:   products.DeletedBy,
 	}
 }
 
@@ -47,13 +132,261 @@ func toEntities(p []Products) []*entity.Products {
 	return products
 }
 
-func (db *DB) GetProductByID(ctx context.Context, id string) (*entity.Products, int, error) {
-	startTime := time.Now()
+// EnsureIndexes to create indexes products queries rely on, and fill in
+// fields they need which older products lack.
+func (db *DB) EnsureIndexes(ctx context.Context) error {
+	_, err := db.db.Collection(db.products).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// Products created before SKU was introduced don't have one.
+			Keys: bson.D{{Key: "sku", Value: 1}},
+			Options: options.Index().
+				SetName("sku_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"sku": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys: bson.D{
+				{Key: "name_product", Value: "text"},
+				{Key: "description", Value: "text"},
+				{Key: "category", Value: "text"},
+				{Key: "tags", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("products_text").
+				SetWeights(bson.D{
+					{Key: "name_product", Value: 10},
+					{Key: "tags", Value: 5},
+					{Key: "category", Value: 3},
+					{Key: "description", Value: 1},
+				}),
+		},
+		{
+			// Lowercase name makes an anchored prefix regex an index scan.
+			Keys:    bson.D{{Key: "name_lower", Value: 1}},
+			Options: options.Index().SetName("name_lower"),
+		},
+		{
+			Keys:    bson.D{{Key: "category", Value: 1}},
+			Options: options.Index().SetName("category"),
+		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("tags"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Products stored before created_at sort before every other product
+	// and can't be paged past by cursor, so they get the time from their
+	// id, as toEntity reports it. Running it again is a no-op.
+	_, err = db.db.Collection(db.products).UpdateMany(ctx,
+		bson.M{"created_at": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"created_at": bson.M{"$toDate": "$_id"}}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	return db.backfillNameLower(ctx)
+}
+
+// backfillNameLower to give products stored before name_lower one, so
+// suggestions and upserts by name find them. It is lowercased here like
+// on every write since $toLower only knows ASCII.
+func (db *DB) backfillNameLower(ctx context.Context) error {
+	cur, err := db.db.Collection(db.products).Find(ctx,
+		bson.M{"name_lower": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"name_product": 1}),
+	)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var models []mongo.WriteModel
+	write := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		_, err := db.db.Collection(db.products).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		models = models[:0]
+		return err
+	}
+
+	for cur.Next(ctx) {
+		var pr Products
+		if err := cur.Decode(&pr); err != nil {
+			return err
+		}
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": pr.ID, "name_lower": bson.M{"$exists": false}}).
+			SetUpdate(bson.M{"$set": bson.M{"name_lower": strings.ToLower(pr.Name)}}))
+
+		if len(models) == backfillBatch {
+			if err := write(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	return write()
+}
+
+// backfillBatch is how many products one backfill write changes.
+const backfillBatch = 1000
+
+// productsFilter to build the query shared by product listings.
+func productsFilter(data entity.GetProductsRequest) bson.M {
+	filter := bson.M{
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	if data.Name != "" {
+		filter["name_product"] = bson.M{"$regex": primitive.Regex{Pattern: data.NamePattern(), Options: "i"}}
+	}
+	if data.SKU != "" {
+		filter["sku"] = data.SKU
+	}
+	if data.Category != "" {
+		filter["category"] = data.Category
+	}
+	if data.Tag != "" {
+		filter["tags"] = data.Tag
+	}
+	if data.Currency != "" {
+		filter["currency"] = data.Currency
+	}
+
+	var stock []bson.M
+	for op, value := range map[string]*int{"$lt": data.StockLT, "$lte": data.StockLTE, "$gt": data.StockGT, "$gte": data.StockGTE} {
+		if value != nil {
+			stock = append(stock, bson.M{"stock": bson.M{op: *value}})
+		}
+	}
+	if data.InStock != nil {
+		if *data.InStock {
+			stock = append(stock, bson.M{"stock": bson.M{"$gt": 0}})
+		} else {
+			stock = append(stock, bson.M{"stock": bson.M{"$lte": 0}})
+		}
+	}
+	if len(stock) > 0 {
+		filter["$and"] = stock
+	}
+
+	return filter
+}
+
+// sortColumns is document field of each product sort field.
+var sortColumns = map[string]string{
+	entity.SortName:      "name_product",
+	entity.SortStock:     "stock",
+	entity.SortCreatedAt: "created_at",
+}
+
+// productsSort to map sort fields to the document, always ending with _id
+// so pages are stable.
+func productsSort(fields []entity.SortField) bson.D {
+	sort := bson.D{}
+	for _, field := range fields {
+		order := 1
+		if field.Desc {
+			order = -1
+		}
+		sort = append(sort, bson.E{Key: sortColumns[field.Field], Value: order})
+	}
+
+	return append(sort, bson.E{Key: "_id", Value: 1})
+}
+
+// productsCursor is sort values and id of the last product of a page.
+// Sort is kept so a cursor can't be reused with another sort.
+type productsCursor struct {
+	Sort   string             `bson:"s"`
+	Values bson.A             `bson:"v"`
+	ID     primitive.ObjectID `bson:"id"`
+}
+
+func encodeCursor(fields []entity.SortField, last Products) (string, error) {
+	cursor := productsCursor{
+		Sort: entity.SortString(fields),
+		ID:   last.ID,
+	}
+	for _, field := range fields {
+		switch field.Field {
+		case entity.SortName:
+			cursor.Values = append(cursor.Values, last.Name)
+		case entity.SortStock:
+			cursor.Values = append(cursor.Values, last.Stock)
+		case entity.SortCreatedAt:
+			// Same fallback as toEntity, which EnsureIndexes also stores.
+			cursor.Values = append(cursor.Values, last.toEntity().CreatedAt)
+		}
+	}
+
+	raw, err := bson.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(str string, fields []entity.SortField) (*productsCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor productsCursor
+	if err := bson.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+
+	if cursor.Sort != entity.SortString(fields) || len(cursor.Values) != len(fields) {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// keysetFilter to match products sorted after the cursor. With sort a, b
+// that is a > x, or a = x and b > y, or a = x and b = y and _id > id.
+func keysetFilter(fields []entity.SortField, cursor *productsCursor) bson.M {
+	var or bson.A
+	equal := bson.M{}
+	for i, field := range fields {
+		op := "$gt"
+		if field.Desc {
+			op = "$lt"
+		}
+
+		after := bson.M{sortColumns[field.Field]: bson.M{op: cursor.Values[i]}}
+		for k, v := range equal {
+			after[k] = v
+		}
+		or = append(or, after)
 
+		equal[sortColumns[field.Field]] = cursor.Values[i]
+	}
+
+	equal["_id"] = bson.M{"$gt": cursor.ID}
+
+	return bson.M{"$or": append(or, equal)}
+}
+
+func (db *DB) GetProductByID(ctx context.Context, id entity.ProductID) (_ *entity.Products, code int, _ error) {
 	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetProductByID")
 	defer span.End()
-	
-	_id, _ := primitive.ObjectIDFromHex(id)
+	defer infrastructure.ObserveRepository(ctx, "GetProductByID", time.Now(), &code)
+
+	_id := objectID(id)
+
 	filter := bson.M{
 		"_id":        _id,
 		"deleted_at": bson.M{"$exists": false},
@@ -61,35 +394,30 @@ func (db *DB) GetProductByID(ctx context.Context, id string) (*entity.Products,
 
 	var pr Products
 	err := db.db.Collection(db.products).FindOne(ctx, filter).Decode(&pr)
-	if err == mongo.ErrNoDocuments {
-		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
 	}
 
-	endTime := time.Now()
-	executionTime := endTime.Sub(startTime)
-
-	log.Println(" Execution Time (Get Product By Id): %s\n", executionTime)
 	return pr.toEntity(), http.StatusOK, nil
 }
 
-func (db *DB) GetCompanies(ctx context.Context, data entity.GetProductsRequest) ([]*entity.Products, *utils.Pagination, int, error) {
-	startTime := time.Now()
+func (db *DB) GetCompanies(ctx context.Context, data entity.GetProductsRequest) (_ []*entity.Products, _ *utils.Pagination, code int, _ error) {
 	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetCompanies")
 	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetCompanies", time.Now(), &code)
 
-	filter := make(map[string]interface{})
-
-	if trimmedName := strings.TrimSpace(data.Name); trimmedName != "" {
-		filter["name_product"] = bson.M{"$regex": primitive.Regex{Pattern: trimmedName, Options: "i"}}
-	}
-
+	filter := productsFilter(data)
 
 	limit := int64(data.Limit)
 	skip := int64(data.Page*data.Limit - data.Limit)
 	options := options.Find()
 
 	options.SetLimit(limit)
-	options.Skip =&skip
+	options.Skip = &skip
+	options.SetSort(productsSort(data.Sort))
 
 	cur, err := db.db.Collection(db.products).Find(ctx, filter, options)
 	if err != nil {
@@ -116,11 +444,6 @@ func (db *DB) GetCompanies(ctx context.Context, data entity.GetProductsRequest)
 	}
 	defer cur.Close(ctx)
 
-	endTime := time.Now()
-	executionTime := endTime.Sub(startTime)
-
-	log.Println(" Execution Time (Get All Products): %s\n", executionTime)
-
 	return toEntities(products), &utils.Pagination{
 		Total:       int(total),
 		Limit:       data.Limit,
@@ -130,92 +453,603 @@ func (db *DB) GetCompanies(ctx context.Context, data entity.GetProductsRequest)
 
 }
 
-func (db *DB) CreateProduct(ctx context.Context, product entity.Products) (*entity.Products, int, error) {
-	startTime := time.Now()
+// CountProducts counts products matching the filter of data. Paging and
+// sort are ignored.
+func (db *DB) CountProducts(ctx context.Context, data entity.GetProductsRequest) (_ int, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CountProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CountProducts", time.Now(), &code)
+
+	total, err := db.db.Collection(db.products).CountDocuments(ctx, productsFilter(data))
+	if err != nil {
+		return 0, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return int(total), http.StatusOK, nil
+}
+
+// GetProductsBySKUOrName returns products with one of skus, and products
+// without sku named one of names regardless of case.
+func (db *DB) GetProductsBySKUOrName(ctx context.Context, skus []string, names []string) (_ []*entity.Products, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetProductsBySKUOrName")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetProductsBySKUOrName", time.Now(), &code)
+
+	// Products stored before name_lower that EnsureIndexes hasn't filled
+	// in yet are matched on the name itself, or upserts by name would
+	// create them again.
+	lowerNames := make([]string, len(names))
+	nameRegexes := make(bson.A, len(names))
+	for i, name := range names {
+		lowerNames[i] = strings.ToLower(name)
+		nameRegexes[i] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}
+	}
+
+	filter := bson.M{
+		"deleted_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"sku": bson.M{"$in": skus}},
+			bson.M{"sku": bson.M{"$exists": false}, "name_lower": bson.M{"$in": lowerNames}},
+			bson.M{"sku": bson.M{"$exists": false}, "name_lower": bson.M{"$exists": false}, "name_product": bson.M{"$in": nameRegexes}},
+		},
+	}
+
+	cur, err := db.db.Collection(db.products).Find(ctx, filter)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var products []Products
+	if err := cur.All(ctx, &products); err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return toEntities(products), http.StatusOK, nil
+}
+
+func (db *DB) GetProductsByCursor(ctx context.Context, data entity.GetProductsRequest, cursor string) (_ []*entity.Products, _ string, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetProductsByCursor")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetProductsByCursor", time.Now(), &code)
+
+	filter := productsFilter(data)
+	if cursor != "" {
+		after, err := decodeCursor(cursor, data.Sort)
+		if err != nil {
+			return nil, "", http.StatusBadRequest, errors.ErrInvalidCursor
+		}
+		filter = bson.M{"$and": bson.A{filter, keysetFilter(data.Sort, after)}}
+	}
+
+	// One more than asked for tells whether there is a next page. No
+	// limit means one page of everything.
+	options := options.Find().SetSort(productsSort(data.Sort))
+	if data.Limit > 0 {
+		options.SetLimit(int64(data.Limit) + 1)
+	}
+
+	cur, err := db.db.Collection(db.products).Find(ctx, filter, options)
+	if err != nil {
+		return nil, "", http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var products []Products
+	if err := cur.All(ctx, &products); err != nil {
+		return nil, "", http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	var next string
+	if data.Limit > 0 && len(products) > data.Limit {
+		products = products[:data.Limit]
+		next, err = encodeCursor(data.Sort, products[len(products)-1])
+		if err != nil {
+			return nil, "", http.StatusInternalServerError, errors.ErrInternalDB
+		}
+	}
+
+	return toEntities(products), next, http.StatusOK, nil
+}
+
+func (db *DB) CreateProduct(ctx context.Context, product entity.Products) (_ *entity.Products, code int, _ error) {
 	ctx, span := infrastructure.Tracer().Start(ctx, "db:CreateProduct")
 	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateProduct", time.Now(), &code)
 
 	data := db.fromEntity(product)
+	data.Version = 1
+	data.CreatedAt = time.Now()
 	res, err := db.db.Collection(db.products).InsertOne(ctx, data)
 	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, http.StatusConflict, errors.ErrDuplicateSKU
+		}
 		return nil, http.StatusInternalServerError, errors.ErrInternalDB
 	}
 
 	data.ID = res.InsertedID.(primitive.ObjectID)
 
-	endTime := time.Now()
-	executionTime := endTime.Sub(startTime)
-
-	log.Println(" Execution Time (Insert New Product): %s\n", executionTime)
 	return data.toEntity(), http.StatusOK, nil
 }
 
-func (db *DB) UpdateProduct(ctx context.Context, id string, updateData entity.UpdateProductsRequest) (*entity.Products, int, error) {
-	startTime := time.Now()
+func (db *DB) UpdateProduct(ctx context.Context, id entity.ProductID, updateData entity.UpdateProductsRequest) (_ *entity.Products, code int, _ error) {
 	ctx, span := infrastructure.Tracer().Start(ctx, "db:UpdateProduct")
 	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "UpdateProduct", time.Now(), &code)
+
+	_id := objectID(id)
 
-	_id, err := primitive.ObjectIDFromHex(id)
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, updateFilter(_id, updateData), updateQuery(updateData), options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&pr)
 	if err != nil {
-		return nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+		if err == mongo.ErrNoDocuments {
+			code, err := db.explainUpdateMiss(ctx, _id, updateData.Version)
+			return nil, code, err
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, http.StatusConflict, errors.ErrDuplicateSKU
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
 	}
 
+	return pr.toEntity(), http.StatusOK, nil
+}
+
+// updateFilter matches the product an update applies to: not deleted, at
+// the expected version if there is one, and when stock is set, holding no
+// more reserved or allocated stock than that.
+func updateFilter(id primitive.ObjectID, updateData entity.UpdateProductsRequest) bson.M {
 	filter := bson.M{
-		"_id": _id,
+		"_id":        id,
+		"deleted_at": bson.M{"$exists": false},
+	}
+	if updateData.Version != nil {
+		filter["version"] = versionFilter(*updateData.Version)
+	}
+	if updateData.Stock != nil {
+		filter["$expr"] = bson.M{"$and": bson.A{
+			bson.M{"$lte": bson.A{reservedExpr, *updateData.Stock}},
+			bson.M{"$lte": bson.A{allocatedExpr, *updateData.Stock}},
+		}}
 	}
+	return filter
+}
 
+// updateQuery sets only the fields an update changes, so anything else
+// changed since the product was read is kept, and bumps the version.
+func updateQuery(updateData entity.UpdateProductsRequest) bson.M {
 	update := bson.M{}
-	if updateData.Name != "" {
-		update["name_product"] = updateData.Name
+	if updateData.Name != nil {
+		update["name_product"] = *updateData.Name
+		update["name_lower"] = strings.ToLower(*updateData.Name)
+	}
+	if updateData.SKU != nil {
+		update["sku"] = *updateData.SKU
+	}
+	if updateData.Description != nil {
+		update["description"] = *updateData.Description
+	}
+	if updateData.Category != nil {
+		update["category"] = *updateData.Category
+	}
+	if updateData.Tags != nil {
+		update["tags"] = updateData.Tags
+	}
+	if updateData.Price != nil {
+		update["price"] = *updateData.Price
+	}
+	if updateData.Currency != nil {
+		update["currency"] = *updateData.Currency
 	}
 	if updateData.Stock != nil {
 		update["stock"] = *updateData.Stock
 	}
 
-	updateQuery := bson.M{"$set": update}
+	query := bson.M{"$inc": bson.M{"version": 1}}
+	if len(update) > 0 {
+		query["$set"] = update
+	}
+	return query
+}
+
+func (db *DB) DeleteProduct(ctx context.Context, id entity.ProductID, deletedBy string) (code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:DeleteProduct")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "DeleteProduct", time.Now(), &code)
+
+	_id := objectID(id)
+
+	filter := bson.M{
+		"_id":        _id,
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	update := bson.M{"$set": bson.M{
+		"deleted_at": time.Now(),
+		"deleted_by": deletedBy,
+	}}
+
+	result, err := db.db.Collection(db.products).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	if result.MatchedCount == 0 {
+		return http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	return http.StatusOK, nil
+}
+
+func (db *DB) RestoreProduct(ctx context.Context, id entity.ProductID) (_ *entity.Products, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:RestoreProduct")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "RestoreProduct", time.Now(), &code)
+
+	_id := objectID(id)
+
+	filter := bson.M{
+		"_id":        _id,
+		"deleted_at": bson.M{"$exists": true},
+	}
+
+	update := bson.M{"$unset": bson.M{
+		"deleted_at": "",
+		"deleted_by": "",
+	}}
 
 	var pr Products
-	err = db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, updateQuery, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&pr)
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&pr)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+			return nil, http.StatusNotFound, errors.ErrNotFoundDeletedProduct
 		}
 		return nil, http.StatusInternalServerError, errors.ErrInternalDB
 	}
 
-	endTime := time.Now()
-	executionTime := endTime.Sub(startTime)
+	return pr.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) PurgeProducts(ctx context.Context, deletedBefore time.Time) (_ int, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:PurgeProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "PurgeProducts", time.Now(), &code)
+
+	// Products still holding reserved stock are kept until their
+	// reservations are done with it.
+	filter := bson.M{
+		"deleted_at": bson.M{"$lt": deletedBefore},
+		"reserved":   bson.M{"$not": bson.M{"$gt": 0}},
+	}
+
+	result, err := db.db.Collection(db.products).DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return int(result.DeletedCount), http.StatusOK, nil
+}
 
-	log.Println(" Execution Time (Update Product By Id): %s\n", executionTime)
+func (db *DB) AdjustStock(ctx context.Context, id entity.ProductID, data entity.AdjustStockRequest) (_ *entity.Products, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:AdjustStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "AdjustStock", time.Now(), &code)
+
+	_id := objectID(id)
+
+	filter := bson.M{
+		"_id":        _id,
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	inc := bson.M{
+		"stock":   data.Delta,
+		"version": 1,
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	if data.WarehouseID != "" {
+		warehouseID, err := primitive.ObjectIDFromHex(data.WarehouseID)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+		}
+
+		if data.Delta > 0 {
+			if err := db.ensureLocation(ctx, _id, warehouseID); err != nil {
+				return nil, http.StatusInternalServerError, errors.ErrInternalDB
+			}
+		}
+
+		if data.Delta < 0 {
+			filter["locations"] = bson.M{"$elemMatch": bson.M{
+				"warehouse_id": warehouseID,
+				"stock":        bson.M{"$gte": -data.Delta},
+			}}
+			filter["$expr"] = availableExpr(-data.Delta)
+		}
+
+		inc["locations.$[loc].stock"] = data.Delta
+		opts.SetArrayFilters(options.ArrayFilters{Filters: bson.A{bson.M{"loc.warehouse_id": warehouseID}}})
+	} else if data.Delta < 0 {
+		filter["$expr"] = bson.M{"$and": bson.A{
+			availableExpr(-data.Delta),
+			unallocatedExpr(-data.Delta),
+		}}
+	}
+
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, bson.M{"$inc": inc}, opts).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			code, err := db.notFoundOr(ctx, _id, http.StatusConflict, errors.ErrInsufficientStock)
+			return nil, code, err
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
 
 	return pr.toEntity(), http.StatusOK, nil
 }
 
-func (db *DB) DeleteProduct(ctx context.Context, id string) (int, error) {
-	startTime := time.Now()
-    _id, err := primitive.ObjectIDFromHex(id)
-    if err != nil {
-        return http.StatusBadRequest, errors.ErrNotFoundProduct
-    }
-
-    filter := bson.M{"_id": _id}
-    
-    // Ganti UpdateOne dengan DeleteOne
-    result, err := db.db.Collection(db.products).DeleteOne(ctx, filter)
-    if err != nil {
-        if err == mongo.ErrNoDocuments {
-            return http.StatusNotFound, errors.ErrNotFoundProduct
-        }
-        return http.StatusInternalServerError, errors.ErrInternalDB
-    }
-
-    if result.DeletedCount == 0 {
-        return http.StatusNotFound, errors.ErrNotFoundProduct
-    }
-
-	endTime := time.Now()
-	executionTime := endTime.Sub(startTime)
-
-	log.Println(" Execution Time (Delete Product By Id): %s\n", executionTime)
-    return http.StatusOK, nil
+func (db *DB) TransferStock(ctx context.Context, id entity.ProductID, data entity.TransferStockRequest) (_ *entity.Products, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:TransferStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "TransferStock", time.Now(), &code)
+
+	_id := objectID(id)
+
+	filter := bson.M{
+		"_id":        _id,
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	inc := bson.M{"version": 1}
+
+	var arrayFilters bson.A
+
+	if data.FromWarehouseID != "" {
+		fromID, err := primitive.ObjectIDFromHex(data.FromWarehouseID)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+		}
+
+		filter["locations"] = bson.M{"$elemMatch": bson.M{
+			"warehouse_id": fromID,
+			"stock":        bson.M{"$gte": data.Quantity},
+		}}
+		inc["locations.$[from].stock"] = -data.Quantity
+		arrayFilters = append(arrayFilters, bson.M{"from.warehouse_id": fromID})
+	} else {
+		filter["$expr"] = unallocatedExpr(data.Quantity)
+	}
+
+	if data.ToWarehouseID != "" {
+		toID, err := primitive.ObjectIDFromHex(data.ToWarehouseID)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+		}
+
+		if err := db.ensureLocation(ctx, _id, toID); err != nil {
+			return nil, http.StatusInternalServerError, errors.ErrInternalDB
+		}
+
+		inc["locations.$[to].stock"] = data.Quantity
+		arrayFilters = append(arrayFilters, bson.M{"to.warehouse_id": toID})
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if len(arrayFilters) > 0 {
+		opts.SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+	}
+
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, bson.M{"$inc": inc}, opts).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			code, err := db.notFoundOr(ctx, _id, http.StatusConflict, errors.ErrInsufficientStock)
+			return nil, code, err
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return pr.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) ReserveStock(ctx context.Context, id entity.ProductID, quantity int) (_ *entity.Products, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:ReserveStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "ReserveStock", time.Now(), &code)
+
+	_id := objectID(id)
+
+	filter := bson.M{
+		"_id":        _id,
+		"deleted_at": bson.M{"$exists": false},
+		"$expr":      availableExpr(quantity),
+	}
+
+	update := bson.M{"$inc": bson.M{"reserved": quantity}}
+
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			code, err := db.notFoundOr(ctx, _id, http.StatusConflict, errors.ErrInsufficientStock)
+			return nil, code, err
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return pr.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) ReleaseStock(ctx context.Context, id entity.ProductID, quantity int) (_ *entity.Products, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:ReleaseStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "ReleaseStock", time.Now(), &code)
+
+	_id := objectID(id)
+
+	// Held stock is given back even if the product was deleted meanwhile.
+	filter := bson.M{
+		"_id":      _id,
+		"reserved": bson.M{"$gte": quantity},
+	}
+
+	update := bson.M{"$inc": bson.M{"reserved": -quantity}}
+
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			count, err := db.db.Collection(db.products).CountDocuments(ctx, bson.M{"_id": _id})
+			if err != nil {
+				return nil, http.StatusInternalServerError, errors.ErrInternalDB
+			}
+			if count == 0 {
+				return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+			}
+			return nil, http.StatusConflict, errors.ErrInsufficientReservedStock
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return pr.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) CommitReservedStock(ctx context.Context, id entity.ProductID, quantity int) (_ *entity.Products, _ []entity.Locations, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CommitReservedStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CommitReservedStock", time.Now(), &code)
+
+	_id := objectID(id)
+
+	// One pipeline update, so the stock, the hold and the warehouses
+	// drawn from all come from the same document. Unallocated stock is
+	// taken first, then warehouses in order, like entity.DrawStock. The
+	// document before the update is returned, since what each warehouse
+	// gave can't be told from the one after.
+	short := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{quantity, unallocatedStockExpr}}}}
+	drawn := bson.M{"$reduce": bson.M{
+		"input":        "$locations",
+		"initialValue": bson.M{"short": short, "locations": bson.A{}},
+		"in": bson.M{
+			"short": bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$$value.short", "$$this.stock"}}}},
+			"locations": bson.M{"$concatArrays": bson.A{"$$value.locations", bson.A{
+				bson.M{"$mergeObjects": bson.A{"$$this", bson.M{
+					"stock": bson.M{"$subtract": bson.A{"$$this.stock", bson.M{"$min": bson.A{"$$value.short", "$$this.stock"}}}},
+				}}},
+			}}},
+		},
+	}}
+
+	filter := bson.M{
+		"_id":      _id,
+		"reserved": bson.M{"$gte": quantity},
+		"stock":    bson.M{"$gte": quantity},
+	}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"stock":    bson.M{"$subtract": bson.A{"$stock", quantity}},
+		"reserved": bson.M{"$subtract": bson.A{reservedExpr, quantity}},
+		"version":  bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$version", 0}}, 1}},
+		"locations": bson.M{"$cond": bson.A{
+			bson.M{"$isArray": "$locations"},
+			bson.M{"$let": bson.M{"vars": bson.M{"drawn": drawn}, "in": "$$drawn.locations"}},
+			"$$REMOVE",
+		}},
+	}}}}
+
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.Before)).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, http.StatusConflict, errors.ErrInsufficientReservedStock
+		}
+		return nil, nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	product, taken := pr.toEntity().CommitReserved(quantity)
+
+	return &product, taken, http.StatusOK, nil
+}
+
+// ensureLocation to add an empty warehouse balance to a product
+// which doesn't have one yet.
+func (db *DB) ensureLocation(ctx context.Context, id, warehouseID primitive.ObjectID) error {
+	_, err := db.db.Collection(db.products).UpdateOne(ctx, bson.M{
+		"_id":                    id,
+		"deleted_at":             bson.M{"$exists": false},
+		"locations.warehouse_id": bson.M{"$ne": warehouseID},
+	}, bson.M{"$push": bson.M{"locations": Locations{
+		WarehouseID: warehouseID,
+		Stock:       0,
+	}}})
+	return err
+}
+
+// reservedExpr is the reserved stock of a product, which older products don't have.
+var reservedExpr = bson.M{"$ifNull": bson.A{"$reserved", 0}}
+
+// allocatedExpr is the product stock assigned to warehouses.
+var allocatedExpr = bson.M{"$sum": "$locations.stock"}
+
+// unallocatedStockExpr is the product stock not assigned to any warehouse.
+var unallocatedStockExpr = bson.M{"$subtract": bson.A{"$stock", allocatedExpr}}
+
+// unallocatedExpr matches products whose stock not assigned to any
+// warehouse is at least the given quantity.
+func unallocatedExpr(quantity int) bson.M {
+	return bson.M{"$gte": bson.A{unallocatedStockExpr, quantity}}
+}
+
+// availableExpr matches products whose stock not held by reservations
+// is at least the given quantity.
+func availableExpr(quantity int) bson.M {
+	return bson.M{"$gte": bson.A{bson.M{"$subtract": bson.A{"$stock", reservedExpr}}, quantity}}
+}
+
+// versionFilter matches the given product version. Products created before
+// versioning was introduced have no version field and are treated as version 0.
+func versionFilter(version int) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// explainUpdateMiss tells why a conditional product update matched nothing.
+func (db *DB) explainUpdateMiss(ctx context.Context, id primitive.ObjectID, version *int) (int, error) {
+	var pr Products
+	err := db.db.Collection(db.products).FindOne(ctx, bson.M{
+		"_id":        id,
+		"deleted_at": bson.M{"$exists": false},
+	}).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return http.StatusNotFound, errors.ErrNotFoundProduct
+		}
+		return http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	if version != nil && pr.Version != *version {
+		return http.StatusPreconditionFailed, errors.ErrProductVersionMismatch
+	}
+	return http.StatusConflict, errors.ErrInsufficientStock
+}
+
+// notFoundOr tells apart a conditional write that failed because the product
+// is gone from one that failed because its condition didn't hold.
+func (db *DB) notFoundOr(ctx context.Context, id primitive.ObjectID, code int, err error) (int, error) {
+	count, cErr := db.db.Collection(db.products).CountDocuments(ctx, bson.M{
+		"_id":        id,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if cErr != nil {
+		return http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	if count == 0 {
+		return http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+	return code, err
 }
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BulkWriteProducts runs all creates in one unordered bulk write, then
+// each update and delete on its own.
+//
+// A bulk write only reports which writes failed, not which matched
+// nothing, and reading products back can't tell an update that matched
+// nothing from a concurrent write that left the product looking the same.
+// So updates are one FindOneAndUpdate each, pinned to the version read,
+// which gives back the product this call wrote or nothing, and the stock
+// delta is exact. Reservations don't change the version, so whether stock
+// still covers what is reserved is part of the filter.
+func (db *DB) BulkWriteProducts(ctx context.Context, operations []entity.BulkOperation) (_ []entity.BulkResult, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:BulkWriteProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "BulkWriteProducts", time.Now(), &code)
+
+	results := make([]entity.BulkResult, len(operations))
+	ids := make([]primitive.ObjectID, len(operations))
+	var existingIDs []primitive.ObjectID
+	for i, op := range operations {
+		results[i] = entity.BulkResult{Index: i}
+		if op.Op == entity.BulkCreate {
+			continue
+		}
+		results[i].ID = op.ID.String()
+
+		_id := objectID(op.ID)
+		ids[i] = _id
+		existingIDs = append(existingIDs, _id)
+	}
+
+	current, err := db.getProductsByIDs(ctx, existingIDs)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	// Bulk write only reports which writes failed, so keep what each
+	// successful create produces by its position in models.
+	var models []mongo.WriteModel
+	var modelIndex []int
+	now := primitive.NewDateTimeFromTime(time.Now()).Time()
+	for i, op := range operations {
+		switch op.Op {
+		case entity.BulkCreate:
+			data := db.fromEntity(op.Product)
+			data.ID = primitive.NewObjectID()
+			data.Version = 1
+			data.CreatedAt = now
+
+			models = append(models, mongo.NewInsertOneModel().SetDocument(data))
+			modelIndex = append(modelIndex, i)
+			results[i].ID = data.ID.Hex()
+			results[i].Product = data.toEntity()
+			results[i].StockDelta = data.Stock
+			results[i].Code = http.StatusOK
+		case entity.BulkUpdate, entity.BulkDelete:
+		default:
+			results[i].Code, results[i].Err = http.StatusBadRequest, errors.ErrInvalidBulkOperation
+		}
+	}
+
+	if len(models) > 0 {
+		_, err := db.db.Collection(db.products).BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		bulkErr, isBulkErr := err.(mongo.BulkWriteException)
+		switch {
+		case err == nil:
+		case isBulkErr && bulkErr.WriteConcernError == nil:
+			for _, writeErr := range bulkErr.WriteErrors {
+				i := modelIndex[writeErr.Index]
+				results[i].Product, results[i].StockDelta = nil, 0
+				results[i].Code, results[i].Err = http.StatusInternalServerError, errors.ErrInternalDB
+				if mongo.IsDuplicateKeyError(writeErr) {
+					results[i].Code, results[i].Err = http.StatusConflict, errors.ErrDuplicateSKU
+				}
+			}
+		default:
+			return nil, http.StatusInternalServerError, errors.ErrInternalDB
+		}
+	}
+
+	for i, op := range operations {
+		switch op.Op {
+		case entity.BulkUpdate:
+			prev, ok := current[ids[i]]
+			if !ok || prev.DeletedAt != nil {
+				results[i].Code, results[i].Err = http.StatusNotFound, errors.ErrNotFoundProduct
+				continue
+			}
+			if op.Update.Version != nil && *op.Update.Version != prev.Version {
+				results[i].Code, results[i].Err = http.StatusPreconditionFailed, errors.ErrProductVersionMismatch
+				continue
+			}
+
+			product, code, err := db.bulkUpdate(ctx, prev, op.Update)
+			if err != nil {
+				results[i].Code, results[i].Err = code, err
+				continue
+			}
+			results[i].Product = product
+			results[i].StockDelta = product.Stock - prev.Stock
+			results[i].Code = http.StatusOK
+		case entity.BulkDelete:
+			res, err := db.db.Collection(db.products).UpdateOne(ctx, bson.M{
+				"_id":        ids[i],
+				"deleted_at": bson.M{"$exists": false},
+			}, bson.M{"$set": bson.M{
+				"deleted_at": now,
+				"deleted_by": op.DeletedBy,
+			}})
+			switch {
+			case err != nil:
+				results[i].Code, results[i].Err = http.StatusInternalServerError, errors.ErrInternalDB
+			case res.MatchedCount == 0:
+				results[i].Code, results[i].Err = http.StatusNotFound, errors.ErrNotFoundProduct
+			default:
+				results[i].Code = http.StatusOK
+			}
+		}
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// bulkUpdate to update prev if it is still at the version it was read at,
+// returning the product as written.
+func (db *DB) bulkUpdate(ctx context.Context, prev Products, update entity.UpdateProductsRequest) (*entity.Products, int, error) {
+	update.Version = &prev.Version
+
+	var pr Products
+	err := db.db.Collection(db.products).FindOneAndUpdate(ctx, updateFilter(prev.ID, update), updateQuery(update), options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&pr)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			code, err := db.explainUpdateMiss(ctx, prev.ID, update.Version)
+			return nil, code, err
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, http.StatusConflict, errors.ErrDuplicateSKU
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return pr.toEntity(), http.StatusOK, nil
+}
+
+// getProductsByIDs returns products by id, deleted or not.
+func (db *DB) getProductsByIDs(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]Products, error) {
+	products := make(map[primitive.ObjectID]Products, len(ids))
+	if len(ids) == 0 {
+		return products, nil
+	}
+
+	cur, err := db.db.Collection(db.products).Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var product Products
+		if err := cur.Decode(&product); err != nil {
+			return nil, err
+		}
+		products[product.ID] = product
+	}
+
+	return products, cur.Err()
+}
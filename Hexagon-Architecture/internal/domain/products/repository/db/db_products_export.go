@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/domain/products/repository"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportBatchSize is how many documents the cursor fetches per round trip.
+const exportBatchSize = 500
+
+// productsIterator decodes products from a mongo cursor one by one.
+type productsIterator struct {
+	cur       *mongo.Cursor
+	product   *entity.Products
+	err       error
+	startTime time.Time
+}
+
+// ExportProducts opens a cursor over products matching the filter, sorted
+// the same way as GetCompanies but without paging.
+func (db *DB) ExportProducts(ctx context.Context, data entity.GetProductsRequest) (repository.ProductsIterator, int, error) {
+	startTime := time.Now()
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:ExportProducts")
+	defer span.End()
+
+	options := options.Find().
+		SetSort(productsSort(data.Sort)).
+		SetBatchSize(exportBatchSize)
+
+	cur, err := db.db.Collection(db.products).Find(ctx, productsFilter(data), options)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return &productsIterator{
+		cur:       cur,
+		startTime: startTime,
+	}, http.StatusOK, nil
+}
+
+func (it *productsIterator) Next(ctx context.Context) bool {
+	if it.err != nil || !it.cur.Next(ctx) {
+		return false
+	}
+
+	var product Products
+	if err := it.cur.Decode(&product); err != nil {
+		it.err = errors.ErrInternalDB
+		return false
+	}
+
+	it.product = product.toEntity()
+	return true
+}
+
+func (it *productsIterator) Product() *entity.Products {
+	return it.product
+}
+
+func (it *productsIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.cur.Err() != nil {
+		return errors.ErrInternalDB
+	}
+	return nil
+}
+
+func (it *productsIterator) Close(ctx context.Context) error {
+	// The export is timed until the cursor is closed since products are
+	// read while the response is written.
+	code := http.StatusOK
+	if it.Err() != nil {
+		code = http.StatusInternalServerError
+	}
+	defer infrastructure.ObserveRepository(ctx, "ExportProducts", it.startTime, &code)
+
+	if err := it.cur.Close(ctx); err != nil {
+		code = http.StatusInternalServerError
+		return errors.ErrInternalDB
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type searchHit struct {
+	Products `bson:",inline"`
+	Score    float64 `bson:"score"`
+}
+
+type facetCount struct {
+	Value string `bson:"_id"`
+	Count int    `bson:"count"`
+}
+
+type searchResult struct {
+	Hits     []searchHit  `bson:"hits"`
+	Category []facetCount `bson:"category"`
+	Stock    []facetCount `bson:"stock"`
+}
+
+func (db *DB) Search(ctx context.Context, data entity.SearchProductsRequest) (_ *entity.SearchResult, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:Search")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "Search", time.Now(), &code)
+
+	match := bson.M{
+		"$text":      bson.M{"$search": data.Query},
+		"deleted_at": bson.M{"$exists": false},
+	}
+	if data.Category != "" {
+		match["category"] = data.Category
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$addFields": bson.M{"score": bson.M{"$meta": "textScore"}}},
+		bson.M{"$facet": bson.M{
+			"hits": bson.A{
+				bson.M{"$sort": bson.D{{Key: "score", Value: -1}, {Key: "_id", Value: 1}}},
+				bson.M{"$limit": data.Limit},
+			},
+			"category": bson.A{
+				bson.M{"$group": bson.M{"_id": "$category", "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"stock": bson.A{
+				bson.M{"$group": bson.M{"_id": stockBucketExpr(), "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+		}},
+	}
+
+	cur, err := db.db.Collection(db.products).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var results []searchResult
+	if err := cur.All(ctx, &results); err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	result := &entity.SearchResult{}
+	if len(results) > 0 {
+		for _, hit := range results[0].Hits {
+			result.Hits = append(result.Hits, entity.SearchHit{
+				Product: *hit.Products.toEntity(),
+				Score:   hit.Score,
+			})
+		}
+		result.Facets.Category = toFacetCounts(results[0].Category)
+		result.Facets.Stock = toFacetCounts(results[0].Stock)
+	}
+
+	return result, http.StatusOK, nil
+}
+
+func (db *DB) SuggestProducts(ctx context.Context, prefix string, limit int) (_ []*entity.Suggestion, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:SuggestProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "SuggestProducts", time.Now(), &code)
+
+	filter := bson.M{
+		"name_lower": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(strings.ToLower(prefix))},
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	options := options.Find().
+		SetProjection(bson.M{"_id": 1, "name_product": 1}).
+		SetSort(bson.D{{Key: "name_lower", Value: 1}, {Key: "_id", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cur, err := db.db.Collection(db.products).Find(ctx, filter, options)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var products []Products
+	if err := cur.All(ctx, &products); err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	suggestions := make([]*entity.Suggestion, len(products))
+	for i, product := range products {
+		suggestions[i] = &entity.Suggestion{
+			ID:   product.ID.Hex(),
+			Name: product.Name,
+		}
+	}
+
+	return suggestions, http.StatusOK, nil
+}
+
+// stockBucketExpr is entity.StockBucket as an aggregation expression.
+func stockBucketExpr() bson.M {
+	return bson.M{"$switch": bson.M{
+		"branches": bson.A{
+			bson.M{"case": bson.M{"$lte": bson.A{"$stock", 0}}, "then": entity.StockBucketOut},
+			bson.M{"case": bson.M{"$lt": bson.A{"$stock", entity.LowStockThreshold}}, "then": entity.StockBucketLow},
+		},
+		"default": entity.StockBucketIn,
+	}}
+}
+
+func toFacetCounts(f []facetCount) []entity.FacetCount {
+	counts := make([]entity.FacetCount, len(f))
+	for i, count := range f {
+		counts[i] = entity.FacetCount{
+			Value: count.Value,
+			Count: count.Count,
+		}
+	}
+	return counts
+}
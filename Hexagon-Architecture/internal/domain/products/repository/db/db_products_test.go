@@ -0,0 +1,130 @@
+package db_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/domain/products/repository"
+	"hexagon-architecture/internal/domain/products/repository/db"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ repository.Repository = (*db.DB)(nil)
+
+// testDB returns products db on a database of its own, dropped when the
+// test ends. Tests using it need a MongoDB at STORE_TEST_DB_URI and are
+// skipped without one.
+func testDB(t *testing.T) (*db.DB, *mongo.Collection) {
+	uri := os.Getenv("STORE_TEST_DB_URI")
+	if uri == "" {
+		t.Skip("STORE_TEST_DB_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	database := client.Database(fmt.Sprintf("store_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		_ = database.Drop(context.Background())
+		_ = client.Disconnect(context.Background())
+	})
+
+	return db.New(database, "products"), database.Collection("products")
+}
+
+func TestGetProductsBySKUOrNameWithoutNameLower(t *testing.T) {
+	ctx := context.Background()
+	products, collection := testDB(t)
+
+	// As stored before name_lower and sku existed.
+	_, err := collection.InsertOne(ctx, bson.M{"name_product": "Banana", "stock": 2})
+	assert.NoError(t, err)
+
+	found, _, err := products.GetProductsBySKUOrName(ctx, nil, []string{"banana"})
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1, "found before the backfill") {
+		assert.Equal(t, "Banana", found[0].Name)
+	}
+
+	assert.NoError(t, products.EnsureIndexes(ctx))
+
+	var stored bson.M
+	assert.NoError(t, collection.FindOne(ctx, bson.M{}).Decode(&stored))
+	assert.Equal(t, "banana", stored["name_lower"])
+	assert.Contains(t, stored, "created_at")
+
+	found, _, err = products.GetProductsBySKUOrName(ctx, nil, []string{"BANANA"})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1, "found after the backfill")
+}
+
+func TestBulkWriteProductsConcurrentStock(t *testing.T) {
+	ctx := context.Background()
+	products, _ := testDB(t)
+
+	product, _, err := products.CreateProduct(ctx, entity.Products{SKU: "APL-1", Name: "Apple", Stock: 100})
+	assert.NoError(t, err)
+	id := entity.ProductID(product.ID)
+
+	// Bulk updates race stock adjustments, which bump the version the
+	// same way. Only writes reported as done may count towards the stock.
+	const rounds = 20
+	var mu sync.Mutex
+	var delta int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			stock := 50 + i
+			results, _, err := products.BulkWriteProducts(ctx, []entity.BulkOperation{
+				{Op: entity.BulkUpdate, ID: id, Update: entity.UpdateProductsRequest{Stock: &stock}},
+			})
+			assert.NoError(t, err)
+			if results[0].Err != nil {
+				assert.Equal(t, http.StatusPreconditionFailed, results[0].Code)
+				continue
+			}
+			assert.Equal(t, stock, results[0].Product.Stock)
+
+			mu.Lock()
+			delta += results[0].StockDelta
+			mu.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			if _, _, err := products.AdjustStock(ctx, id, entity.AdjustStockRequest{Delta: 1}); err != nil {
+				continue
+			}
+
+			mu.Lock()
+			delta++
+			mu.Unlock()
+		}
+	}()
+	wg.Wait()
+
+	current, _, err := products.GetProductByID(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, 100+delta, current.Stock)
+}
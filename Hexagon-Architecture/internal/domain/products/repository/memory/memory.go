@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"hexagon-architecture/internal/domain/products/entity"
+)
+
+// Memory is contains functions for in-memory products storage.
+type Memory struct {
+	mu       sync.RWMutex
+	ids      []string
+	products map[string]entity.Products
+	// lastID is the counter product ids are made from, so they sort in
+	// creation order like ObjectIDs do.
+	lastID int
+}
+
+// New to create new in-memory products storage.
+func New() *Memory {
+	return &Memory{
+		products: make(map[string]entity.Products),
+	}
+}
+
+// Snapshot to copy the products, returning a function putting them back.
+// Stored products are never changed in place, so the copy shares them.
+func (m *Memory) Snapshot() func() {
+	m.mu.RLock()
+	ids := slices.Clone(m.ids)
+	products := maps.Clone(m.products)
+	m.mu.RUnlock()
+
+	return func() {
+		m.mu.Lock()
+		m.ids, m.products = ids, products
+		m.mu.Unlock()
+	}
+}
@@ -0,0 +1,665 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Memory) GetProductByID(ctx context.Context, id entity.ProductID) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetProductByID")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetProductByID", time.Now(), &code)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) GetCompanies(ctx context.Context, data entity.GetProductsRequest) (_ []*entity.Products, _ *utils.Pagination, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetCompanies")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetCompanies", time.Now(), &code)
+
+	filtered, code, err := m.getProducts(data)
+	if err != nil {
+		return nil, nil, code, err
+	}
+
+	skip := data.Page*data.Limit - data.Limit
+	if skip < 0 {
+		skip = 0
+	}
+
+	var products []*entity.Products
+	for i := skip; i < len(filtered) && (data.Limit <= 0 || i < skip+data.Limit); i++ {
+		product := filtered[i]
+		products = append(products, &product)
+	}
+
+	return products, &utils.Pagination{
+		Total:       len(filtered),
+		Limit:       data.Limit,
+		CurrentPage: data.Page,
+		LastPage:    0,
+	}, http.StatusOK, nil
+}
+
+// CountProducts counts products matching the filter of data. Paging and
+// sort are ignored.
+func (m *Memory) CountProducts(ctx context.Context, data entity.GetProductsRequest) (_ int, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CountProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CountProducts", time.Now(), &code)
+
+	filtered, code, err := m.getProducts(data)
+	if err != nil {
+		return 0, code, err
+	}
+
+	return len(filtered), http.StatusOK, nil
+}
+
+// GetProductsBySKUOrName returns products with one of skus, and products
+// without sku named one of names regardless of case.
+func (m *Memory) GetProductsBySKUOrName(ctx context.Context, skus []string, names []string) (_ []*entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetProductsBySKUOrName")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetProductsBySKUOrName", time.Now(), &code)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var products []*entity.Products
+	for _, id := range m.ids {
+		product := clone(m.products[id])
+		if product.DeletedAt != nil {
+			continue
+		}
+
+		match := slices.Contains(skus, product.SKU)
+		if product.SKU == "" {
+			match = slices.ContainsFunc(names, func(name string) bool {
+				return strings.EqualFold(name, product.Name)
+			})
+		}
+		if match {
+			products = append(products, &product)
+		}
+	}
+
+	return products, http.StatusOK, nil
+}
+
+func (m *Memory) GetProductsByCursor(ctx context.Context, data entity.GetProductsRequest, cursor string) (_ []*entity.Products, _ string, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetProductsByCursor")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetProductsByCursor", time.Now(), &code)
+
+	var after *entity.Products
+	if cursor != "" {
+		var err error
+		if after, err = decodeCursor(cursor, data.Sort); err != nil {
+			return nil, "", http.StatusBadRequest, errors.ErrInvalidCursor
+		}
+	}
+
+	filtered, code, err := m.getProducts(data)
+	if err != nil {
+		return nil, "", code, err
+	}
+
+	var products []*entity.Products
+	for i := range filtered {
+		if after != nil && !less(*after, filtered[i], data.Sort) {
+			continue
+		}
+		if data.Limit > 0 && len(products) == data.Limit {
+			next, err := encodeCursor(data.Sort, *products[len(products)-1])
+			if err != nil {
+				return nil, "", http.StatusInternalServerError, errors.ErrInternalDB
+			}
+			return products, next, http.StatusOK, nil
+		}
+		products = append(products, &filtered[i])
+	}
+
+	return products, "", http.StatusOK, nil
+}
+
+// getProducts returns sorted products matching the filter.
+func (m *Memory) getProducts(data entity.GetProductsRequest) ([]entity.Products, int, error) {
+	var nameRegex *regexp.Regexp
+	if data.Name != "" {
+		re, err := regexp.Compile("(?i)" + data.NamePattern())
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.ErrInvalidNameRegex
+		}
+		nameRegex = re
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var filtered []entity.Products
+	for _, id := range m.ids {
+		product := clone(m.products[id])
+		if product.DeletedAt != nil {
+			continue
+		}
+		if nameRegex != nil && !nameRegex.MatchString(product.Name) {
+			continue
+		}
+		if !matchesFilter(product, data) {
+			continue
+		}
+		filtered = append(filtered, product)
+	}
+
+	sort.Slice(filtered, func(i, j int) bool {
+		return less(filtered[i], filtered[j], data.Sort)
+	})
+
+	return filtered, http.StatusOK, nil
+}
+
+// productsCursor is sort values and id of the last product of a page.
+type productsCursor struct {
+	Sort      string    `json:"s"`
+	Name      string    `json:"n,omitempty"`
+	Stock     int       `json:"q,omitempty"`
+	CreatedAt time.Time `json:"c,omitempty"`
+	ID        string    `json:"id"`
+}
+
+func encodeCursor(fields []entity.SortField, last entity.Products) (string, error) {
+	raw, err := json.Marshal(productsCursor{
+		Sort:      entity.SortString(fields),
+		Name:      last.Name,
+		Stock:     last.Stock,
+		CreatedAt: last.CreatedAt,
+		ID:        last.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(str string, fields []entity.SortField) (*entity.Products, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor productsCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+
+	if cursor.Sort != entity.SortString(fields) || cursor.ID == "" {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	return &entity.Products{
+		ID:        cursor.ID,
+		Name:      cursor.Name,
+		Stock:     cursor.Stock,
+		CreatedAt: cursor.CreatedAt,
+	}, nil
+}
+
+func (m *Memory) CreateProduct(ctx context.Context, product entity.Products) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CreateProduct")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateProduct", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.skuTaken(product.SKU, "") {
+		return nil, http.StatusConflict, errors.ErrDuplicateSKU
+	}
+
+	m.lastID++
+	product.ID = fmt.Sprintf("%024x", m.lastID)
+	product.Version = 1
+	product.CreatedAt = time.Now()
+	m.products[product.ID] = clone(product)
+	m.ids = append(m.ids, product.ID)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) UpdateProduct(ctx context.Context, id entity.ProductID, updateData entity.UpdateProductsRequest) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:UpdateProduct")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "UpdateProduct", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	if updateData.Version != nil && *updateData.Version != product.Version {
+		return nil, http.StatusPreconditionFailed, errors.ErrProductVersionMismatch
+	}
+
+	if updateData.Stock != nil && (*updateData.Stock < product.Reserved || *updateData.Stock < product.Allocated()) {
+		return nil, http.StatusConflict, errors.ErrInsufficientStock
+	}
+
+	if updateData.SKU != nil && m.skuTaken(*updateData.SKU, id.String()) {
+		return nil, http.StatusConflict, errors.ErrDuplicateSKU
+	}
+
+	product = product.Apply(updateData)
+	product.Version++
+
+	m.products[id.String()] = clone(product)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) BulkWriteProducts(ctx context.Context, operations []entity.BulkOperation) (_ []entity.BulkResult, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:BulkWriteProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "BulkWriteProducts", time.Now(), &code)
+
+	results := make([]entity.BulkResult, len(operations))
+	for i, op := range operations {
+		result := entity.BulkResult{Index: i}
+		if op.Op != entity.BulkCreate {
+			result.ID = op.ID.String()
+		}
+
+		switch op.Op {
+		case entity.BulkCreate:
+			product, code, err := m.CreateProduct(ctx, op.Product)
+			result.Code, result.Err = code, err
+			if err == nil {
+				result.ID = product.ID
+				result.Product = product
+				result.StockDelta = product.Stock
+			}
+		case entity.BulkUpdate:
+			current, code, err := m.GetProductByID(ctx, op.ID)
+			if err != nil {
+				result.Code, result.Err = code, err
+				break
+			}
+
+			// Pinned like the mongo adapter so the delta is exact.
+			update := op.Update
+			if update.Version == nil {
+				update.Version = &current.Version
+			}
+
+			product, code, err := m.UpdateProduct(ctx, op.ID, update)
+			result.Code, result.Err = code, err
+			if err == nil {
+				result.Product = product
+				result.StockDelta = product.Stock - current.Stock
+			}
+		case entity.BulkDelete:
+			result.Code, result.Err = m.DeleteProduct(ctx, op.ID, op.DeletedBy)
+		default:
+			result.Code, result.Err = http.StatusBadRequest, errors.ErrInvalidBulkOperation
+		}
+
+		results[i] = result
+	}
+
+	return results, http.StatusOK, nil
+}
+
+func (m *Memory) DeleteProduct(ctx context.Context, id entity.ProductID, deletedBy string) (code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:DeleteProduct")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "DeleteProduct", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt != nil {
+		return http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	now := time.Now()
+	product.DeletedAt = &now
+	product.DeletedBy = deletedBy
+	m.products[id.String()] = clone(product)
+
+	return http.StatusOK, nil
+}
+
+func (m *Memory) RestoreProduct(ctx context.Context, id entity.ProductID) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:RestoreProduct")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "RestoreProduct", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt == nil {
+		return nil, http.StatusNotFound, errors.ErrNotFoundDeletedProduct
+	}
+
+	product.DeletedAt = nil
+	product.DeletedBy = ""
+	m.products[id.String()] = clone(product)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) PurgeProducts(ctx context.Context, deletedBefore time.Time) (_ int, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:PurgeProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "PurgeProducts", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int
+	ids := m.ids[:0]
+	for _, id := range m.ids {
+		product := m.products[id]
+		// Products still holding reserved stock are kept until their
+		// reservations are done with it.
+		if product.DeletedAt != nil && product.DeletedAt.Before(deletedBefore) && product.Reserved <= 0 {
+			delete(m.products, id)
+			purged++
+			continue
+		}
+		ids = append(ids, id)
+	}
+	m.ids = ids
+
+	return purged, http.StatusOK, nil
+}
+
+func (m *Memory) AdjustStock(ctx context.Context, id entity.ProductID, data entity.AdjustStockRequest) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:AdjustStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "AdjustStock", time.Now(), &code)
+
+	if data.WarehouseID != "" {
+		if _, err := primitive.ObjectIDFromHex(data.WarehouseID); err != nil {
+			return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	if product.Stock-product.Reserved+data.Delta < 0 {
+		return nil, http.StatusConflict, errors.ErrInsufficientStock
+	}
+
+	if data.WarehouseID == "" {
+		if product.Unallocated()+data.Delta < 0 {
+			return nil, http.StatusConflict, errors.ErrInsufficientStock
+		}
+	} else {
+		locations := withLocation(product.Locations, data.WarehouseID)
+		i := locationIndex(locations, data.WarehouseID)
+		if locations[i].Stock+data.Delta < 0 {
+			return nil, http.StatusConflict, errors.ErrInsufficientStock
+		}
+		locations[i].Stock += data.Delta
+		product.Locations = locations
+	}
+
+	product.Stock += data.Delta
+	product.Version++
+	m.products[id.String()] = clone(product)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) TransferStock(ctx context.Context, id entity.ProductID, data entity.TransferStockRequest) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:TransferStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "TransferStock", time.Now(), &code)
+
+	for _, warehouseID := range []string{data.FromWarehouseID, data.ToWarehouseID} {
+		if warehouseID == "" {
+			continue
+		}
+		if _, err := primitive.ObjectIDFromHex(warehouseID); err != nil {
+			return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	locations := withLocation(product.Locations, data.ToWarehouseID)
+
+	if data.FromWarehouseID == "" {
+		if product.Unallocated() < data.Quantity {
+			return nil, http.StatusConflict, errors.ErrInsufficientStock
+		}
+	} else {
+		i := locationIndex(locations, data.FromWarehouseID)
+		if i < 0 || locations[i].Stock < data.Quantity {
+			return nil, http.StatusConflict, errors.ErrInsufficientStock
+		}
+		locations[i].Stock -= data.Quantity
+	}
+
+	if data.ToWarehouseID != "" {
+		locations[locationIndex(locations, data.ToWarehouseID)].Stock += data.Quantity
+	}
+
+	product.Locations = locations
+	product.Version++
+	m.products[id.String()] = clone(product)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) ReserveStock(ctx context.Context, id entity.ProductID, quantity int) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:ReserveStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "ReserveStock", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.DeletedAt != nil {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+
+	if product.Stock-product.Reserved < quantity {
+		return nil, http.StatusConflict, errors.ErrInsufficientStock
+	}
+
+	product.Reserved += quantity
+	m.products[id.String()] = clone(product)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) ReleaseStock(ctx context.Context, id entity.ProductID, quantity int) (_ *entity.Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:ReleaseStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "ReleaseStock", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+	if product.Reserved < quantity {
+		return nil, http.StatusConflict, errors.ErrInsufficientReservedStock
+	}
+
+	product.Reserved -= quantity
+	m.products[id.String()] = clone(product)
+
+	return &product, http.StatusOK, nil
+}
+
+func (m *Memory) CommitReservedStock(ctx context.Context, id entity.ProductID, quantity int) (_ *entity.Products, _ []entity.Locations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CommitReservedStock")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CommitReservedStock", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.product(id.String())
+	if !ok || product.Reserved < quantity || product.Stock < quantity {
+		return nil, nil, http.StatusConflict, errors.ErrInsufficientReservedStock
+	}
+
+	product, taken := product.CommitReserved(quantity)
+	m.products[id.String()] = clone(product)
+
+	return &product, taken, http.StatusOK, nil
+}
+
+// product returns a copy of the stored product of id.
+func (m *Memory) product(id string) (entity.Products, bool) {
+	product, ok := m.products[id]
+	return clone(product), ok
+}
+
+// clone returns a copy of product sharing nothing with it, so callers
+// can't change stored products without holding the lock.
+func clone(product entity.Products) entity.Products {
+	product.Tags = slices.Clone(product.Tags)
+	product.Locations = slices.Clone(product.Locations)
+	if product.DeletedAt != nil {
+		deletedAt := *product.DeletedAt
+		product.DeletedAt = &deletedAt
+	}
+	return product
+}
+
+// withLocation returns a copy of locations which has a balance for the
+// given warehouse. Stored products are never changed in place.
+func withLocation(l []entity.Locations, warehouseID string) []entity.Locations {
+	locations := make([]entity.Locations, len(l), len(l)+1)
+	copy(locations, l)
+	if warehouseID != "" && locationIndex(locations, warehouseID) < 0 {
+		locations = append(locations, entity.Locations{WarehouseID: warehouseID})
+	}
+	return locations
+}
+
+func locationIndex(locations []entity.Locations, warehouseID string) int {
+	for i, location := range locations {
+		if location.WarehouseID == warehouseID {
+			return i
+		}
+	}
+	return -1
+}
+
+// skuTaken reports whether another product, deleted or not, already uses sku.
+// Like the unique index in mongo, products without a sku never collide.
+func (m *Memory) skuTaken(sku string, exceptID string) bool {
+	if sku == "" {
+		return false
+	}
+	for id, product := range m.products {
+		if id != exceptID && product.SKU == sku {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesFilter(product entity.Products, data entity.GetProductsRequest) bool {
+	if data.SKU != "" && product.SKU != data.SKU {
+		return false
+	}
+	if data.Category != "" && product.Category != data.Category {
+		return false
+	}
+	if data.Currency != "" && product.Currency != data.Currency {
+		return false
+	}
+	if data.Tag != "" && !slices.Contains(product.Tags, data.Tag) {
+		return false
+	}
+	if data.StockLT != nil && product.Stock >= *data.StockLT {
+		return false
+	}
+	if data.StockLTE != nil && product.Stock > *data.StockLTE {
+		return false
+	}
+	if data.StockGT != nil && product.Stock <= *data.StockGT {
+		return false
+	}
+	if data.StockGTE != nil && product.Stock < *data.StockGTE {
+		return false
+	}
+	if data.InStock != nil && *data.InStock != (product.Stock > 0) {
+		return false
+	}
+	return true
+}
+
+// less reports whether product a sorts before b. Ties are broken by id,
+// which follows insertion order like _id in mongo.
+func less(a, b entity.Products, fields []entity.SortField) bool {
+	for _, field := range fields {
+		var cmp int
+		switch field.Field {
+		case entity.SortName:
+			cmp = strings.Compare(a.Name, b.Name)
+		case entity.SortStock:
+			cmp = a.Stock - b.Stock
+		case entity.SortCreatedAt:
+			cmp = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if field.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/domain/products/repository"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+)
+
+// productsIterator walks a snapshot of products taken when the export
+// started.
+type productsIterator struct {
+	products []entity.Products
+	current  int
+	err      error
+}
+
+func (m *Memory) ExportProducts(ctx context.Context, data entity.GetProductsRequest) (_ repository.ProductsIterator, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:ExportProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "ExportProducts", time.Now(), &code)
+
+	products, code, err := m.getProducts(data)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return &productsIterator{
+		products: products,
+		current:  -1,
+	}, http.StatusOK, nil
+}
+
+func (it *productsIterator) Next(ctx context.Context) bool {
+	if it.err = ctx.Err(); it.err != nil || it.current+1 >= len(it.products) {
+		return false
+	}
+	it.current++
+	return true
+}
+
+func (it *productsIterator) Product() *entity.Products {
+	return &it.products[it.current]
+}
+
+func (it *productsIterator) Err() error {
+	return it.err
+}
+
+func (it *productsIterator) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Search weights, the same as the mongo text index.
+const (
+	nameWeight        = 10
+	tagsWeight        = 5
+	categoryWeight    = 3
+	descriptionWeight = 1
+)
+
+func (m *Memory) Search(ctx context.Context, data entity.SearchProductsRequest) (_ *entity.SearchResult, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:Search")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "Search", time.Now(), &code)
+
+	terms := searchTerms(data.Query)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var hits []entity.SearchHit
+	category := make(map[string]int)
+	stock := make(map[string]int)
+	for _, id := range m.ids {
+		product := clone(m.products[id])
+		if product.DeletedAt != nil {
+			continue
+		}
+		if data.Category != "" && product.Category != data.Category {
+			continue
+		}
+
+		score := searchScore(product, terms)
+		if score == 0 {
+			continue
+		}
+
+		hits = append(hits, entity.SearchHit{Product: product, Score: score})
+		category[product.Category]++
+		stock[entity.StockBucket(product.Stock)]++
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > data.Limit {
+		hits = hits[:data.Limit]
+	}
+
+	return &entity.SearchResult{
+		Hits: hits,
+		Facets: entity.SearchFacets{
+			Category: toFacetCounts(category),
+			Stock:    toFacetCounts(stock),
+		},
+	}, http.StatusOK, nil
+}
+
+func (m *Memory) SuggestProducts(ctx context.Context, prefix string, limit int) (_ []*entity.Suggestion, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:SuggestProducts")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "SuggestProducts", time.Now(), &code)
+
+	prefix = strings.ToLower(prefix)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var suggestions []*entity.Suggestion
+	for _, id := range m.ids {
+		product := m.products[id]
+		if product.DeletedAt != nil || !strings.HasPrefix(strings.ToLower(product.Name), prefix) {
+			continue
+		}
+		suggestions = append(suggestions, &entity.Suggestion{
+			ID:   product.ID,
+			Name: product.Name,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return strings.ToLower(suggestions[i].Name) < strings.ToLower(suggestions[j].Name)
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	return suggestions, http.StatusOK, nil
+}
+
+// searchTerms splits text into lowercase words.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// searchScore is a rough take on mongo text score: weighted count of
+// query terms found in each field.
+func searchScore(product entity.Products, terms []string) float64 {
+	fields := []struct {
+		words  []string
+		weight float64
+	}{
+		{searchTerms(product.Name), nameWeight},
+		{searchTerms(strings.Join(product.Tags, " ")), tagsWeight},
+		{searchTerms(product.Category), categoryWeight},
+		{searchTerms(product.Description), descriptionWeight},
+	}
+
+	var score float64
+	for _, field := range fields {
+		for _, word := range field.words {
+			for _, term := range terms {
+				if word == term {
+					score += field.weight
+				}
+			}
+		}
+	}
+	return score
+}
+
+func toFacetCounts(counts map[string]int) []entity.FacetCount {
+	facets := make([]entity.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, entity.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	return facets
+}
@@ -0,0 +1,282 @@
+package memory_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/domain/products/repository"
+	"hexagon-architecture/internal/domain/products/repository/memory"
+	"hexagon-architecture/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var _ repository.Repository = (*memory.Memory)(nil)
+
+func TestGetCompanies(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+	for _, product := range []entity.Products{
+		{Name: "Apple", Stock: 3},
+		{Name: "Banana", Stock: 0},
+		{Name: "apricot", Stock: 5},
+		{Name: "Cherry", Stock: 1},
+	} {
+		_, code, err := m.CreateProduct(ctx, product)
+		assert.Equal(t, http.StatusOK, code)
+		assert.NoError(t, err)
+	}
+
+	inStock, one, three := true, 1, 3
+	tests := map[string]struct {
+		input         entity.GetProductsRequest
+		expectedNames []string
+		expectedTotal int
+	}{
+		"first-page": {
+			input:         entity.GetProductsRequest{Page: 1, Limit: 3},
+			expectedNames: []string{"Apple", "Banana", "apricot"},
+			expectedTotal: 4,
+		},
+		"second-page": {
+			input:         entity.GetProductsRequest{Page: 2, Limit: 3},
+			expectedNames: []string{"Cherry"},
+			expectedTotal: 4,
+		},
+		"name-case-insensitive": {
+			input:         entity.GetProductsRequest{Page: 1, Limit: 5, Name: "ap"},
+			expectedNames: []string{"Apple", "apricot"},
+			expectedTotal: 2,
+		},
+		"sort-stock-desc": {
+			input:         entity.GetProductsRequest{Page: 1, Limit: 5, Sort: []entity.SortField{{Field: entity.SortStock, Desc: true}}},
+			expectedNames: []string{"apricot", "Apple", "Cherry", "Banana"},
+			expectedTotal: 4,
+		},
+		"sort-name": {
+			input:         entity.GetProductsRequest{Page: 1, Limit: 5, Sort: []entity.SortField{{Field: entity.SortName}}},
+			expectedNames: []string{"Apple", "Banana", "Cherry", "apricot"},
+			expectedTotal: 4,
+		},
+		"in-stock": {
+			input:         entity.GetProductsRequest{Page: 1, Limit: 5, InStock: &inStock},
+			expectedNames: []string{"Apple", "apricot", "Cherry"},
+			expectedTotal: 3,
+		},
+		"stock-range": {
+			input:         entity.GetProductsRequest{Page: 1, Limit: 5, StockGT: &one, StockLTE: &three},
+			expectedNames: []string{"Apple"},
+			expectedTotal: 1,
+		},
+		"out-of-range": {
+			input:         entity.GetProductsRequest{Page: 3, Limit: 5},
+			expectedNames: nil,
+			expectedTotal: 4,
+		},
+	}
+
+	for name, test := range tests {
+		products, pagination, code, err := m.GetCompanies(ctx, test.input)
+		assert.NoError(t, err, name)
+		assert.Equal(t, http.StatusOK, code, name)
+		assert.Equal(t, test.expectedTotal, pagination.Total, name)
+
+		var names []string
+		for _, p := range products {
+			names = append(names, p.Name)
+		}
+		assert.Equal(t, test.expectedNames, names, name)
+	}
+}
+
+func TestGetProductsByCursor(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+	for i, stock := range []int{2, 5, 2, 0, 5, 2, 1} {
+		_, _, err := m.CreateProduct(ctx, entity.Products{Name: string(rune('a' + i)), Stock: stock})
+		assert.NoError(t, err)
+	}
+
+	request := entity.GetProductsRequest{Limit: 3, Sort: []entity.SortField{{Field: entity.SortStock, Desc: true}}}
+
+	var names []string
+	var cursor string
+	for pages := 0; pages == 0 || cursor != ""; pages++ {
+		products, next, code, err := m.GetProductsByCursor(ctx, request, cursor)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		for _, p := range products {
+			names = append(names, p.Name)
+		}
+		cursor = next
+
+		if pages > 3 {
+			t.Fatal("cursor does not end")
+		}
+	}
+	assert.Equal(t, []string{"b", "e", "a", "c", "f", "g", "d"}, names)
+
+	_, next, _, _ := m.GetProductsByCursor(ctx, request, "")
+	request.Sort = nil
+	_, _, code, err := m.GetProductsByCursor(ctx, request, next)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrInvalidCursor, err)
+
+	_, _, code, err = m.GetProductsByCursor(ctx, request, "not-a-cursor")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrInvalidCursor, err)
+
+	products, next, code, err := m.GetProductsByCursor(ctx, entity.GetProductsRequest{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, products, 7, "no limit is one page of everything")
+	assert.Empty(t, next)
+}
+
+func TestProductLifecycle(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	created, _, err := m.CreateProduct(ctx, entity.Products{Name: "Apple", Stock: 10})
+	assert.NoError(t, err)
+
+	stock := 3
+	updated, code, err := m.UpdateProduct(ctx, entity.ProductID(created.ID), entity.UpdateProductsRequest{Stock: &stock})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Apple", updated.Name)
+	assert.Equal(t, 3, updated.Stock)
+	assert.Equal(t, 2, updated.Version)
+
+	staleVersion := 1
+	_, code, err = m.UpdateProduct(ctx, entity.ProductID(created.ID), entity.UpdateProductsRequest{Stock: &stock, Version: &staleVersion})
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, errors.ErrProductVersionMismatch, err)
+
+	_, code, err = m.UpdateProduct(ctx, "", entity.UpdateProductsRequest{Stock: &stock})
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, errors.ErrNotFoundProduct, err)
+
+	code, err = m.DeleteProduct(ctx, entity.ProductID(created.ID), "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, code, err = m.GetProductByID(ctx, entity.ProductID(created.ID))
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, errors.ErrNotFoundProduct, err)
+
+	_, code, err = m.UpdateProduct(ctx, entity.ProductID(created.ID), entity.UpdateProductsRequest{Stock: &stock})
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, errors.ErrNotFoundProduct, err)
+
+	code, err = m.DeleteProduct(ctx, entity.ProductID(created.ID), "tester")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, errors.ErrNotFoundProduct, err)
+
+	restored, code, err := m.RestoreProduct(ctx, entity.ProductID(created.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, restored.DeletedAt)
+
+	_, code, err = m.RestoreProduct(ctx, entity.ProductID(created.ID))
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, errors.ErrNotFoundDeletedProduct, err)
+}
+
+func TestPurgeProducts(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	kept, _, _ := m.CreateProduct(ctx, entity.Products{Name: "Apple", Stock: 1})
+	deleted, _, _ := m.CreateProduct(ctx, entity.Products{Name: "Banana", Stock: 1})
+	_, _ = m.DeleteProduct(ctx, entity.ProductID(deleted.ID), "tester")
+
+	purged, code, err := m.PurgeProducts(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 0, purged)
+
+	purged, _, _ = m.PurgeProducts(ctx, time.Now().Add(time.Hour))
+	assert.Equal(t, 1, purged)
+
+	_, code, _ = m.RestoreProduct(ctx, entity.ProductID(deleted.ID))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, code, _ = m.GetProductByID(ctx, entity.ProductID(kept.ID))
+	assert.Equal(t, http.StatusOK, code)
+
+	reserved, _, _ := m.CreateProduct(ctx, entity.Products{Name: "Cherry", Stock: 2})
+	_, _, _ = m.ReserveStock(ctx, entity.ProductID(reserved.ID), 1)
+	_, _ = m.DeleteProduct(ctx, entity.ProductID(reserved.ID), "tester")
+
+	purged, _, _ = m.PurgeProducts(ctx, time.Now().Add(time.Hour))
+	assert.Equal(t, 0, purged, "reserved stock keeps a product")
+
+	_, _, _ = m.ReleaseStock(ctx, entity.ProductID(reserved.ID), 1)
+	purged, _, _ = m.PurgeProducts(ctx, time.Now().Add(time.Hour))
+	assert.Equal(t, 1, purged)
+
+	_, code, err = m.ReleaseStock(ctx, entity.ProductID(reserved.ID), 1)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, errors.ErrNotFoundProduct, err)
+}
+
+func TestAdjustStock(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	product, _, _ := m.CreateProduct(ctx, entity.Products{Name: "Apple", Stock: 5})
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, code, _ := m.AdjustStock(ctx, entity.ProductID(product.ID), entity.AdjustStockRequest{Delta: -1, Reason: "sale"})
+			codes <- code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	count := map[int]int{}
+	for code := range codes {
+		count[code]++
+	}
+	assert.Equal(t, map[int]int{http.StatusOK: 5, http.StatusConflict: 5}, count)
+
+	adjusted, code, err := m.AdjustStock(ctx, entity.ProductID(product.ID), entity.AdjustStockRequest{Delta: 3, Reason: "restock"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, adjusted.Stock)
+	assert.Equal(t, 7, adjusted.Version)
+}
+
+func TestProductsAreCopied(t *testing.T) {
+	ctx := context.Background()
+	m := memory.New()
+
+	tags := []string{"fruit"}
+	created, _, err := m.CreateProduct(ctx, entity.Products{Name: "Apple", Tags: tags, Stock: 10})
+	assert.NoError(t, err)
+	tags[0] = "changed"
+	created.Tags[0] = "changed"
+
+	adjusted, _, err := m.AdjustStock(ctx, entity.ProductID(created.ID), entity.AdjustStockRequest{Delta: 1, Reason: "restock"})
+	assert.NoError(t, err)
+	adjusted.Tags[0] = "changed"
+
+	found, _, err := m.GetProductByID(ctx, entity.ProductID(created.ID))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fruit"}, found.Tags)
+	found.Tags[0] = "changed"
+
+	listed, _, _, err := m.GetCompanies(ctx, entity.GetProductsRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fruit"}, listed[0].Tags)
+}
@@ -4,12 +4,36 @@ import (
 	"context"
 	"hexagon-architecture/internal/domain/products/entity"
 	"hexagon-architecture/internal/utils"
+	"time"
 )
 
 type Repository interface {
-	GetProductByID(ctx context.Context, id string) (*entity.Products, int, error)
+	GetProductByID(ctx context.Context, id entity.ProductID) (*entity.Products, int, error)
 	GetCompanies(ctx context.Context, data entity.GetProductsRequest) ([]*entity.Products, *utils.Pagination, int, error)
+	CountProducts(ctx context.Context, data entity.GetProductsRequest) (int, int, error)
+	GetProductsBySKUOrName(ctx context.Context, skus []string, names []string) ([]*entity.Products, int, error)
+	GetProductsByCursor(ctx context.Context, data entity.GetProductsRequest, cursor string) ([]*entity.Products, string, int, error)
+	ExportProducts(ctx context.Context, data entity.GetProductsRequest) (ProductsIterator, int, error)
+	Search(ctx context.Context, data entity.SearchProductsRequest) (*entity.SearchResult, int, error)
+	SuggestProducts(ctx context.Context, prefix string, limit int) ([]*entity.Suggestion, int, error)
 	CreateProduct(ctx context.Context, product entity.Products) (*entity.Products, int, error)
-	UpdateProduct(ctx context.Context, id string, updateData entity.UpdateProductsRequest) (*entity.Products, int, error)
-	DeleteProduct(ctx context.Context, id string) (int, error)
-}
\ No newline at end of file
+	UpdateProduct(ctx context.Context, id entity.ProductID, updateData entity.UpdateProductsRequest) (*entity.Products, int, error)
+	BulkWriteProducts(ctx context.Context, operations []entity.BulkOperation) ([]entity.BulkResult, int, error)
+	DeleteProduct(ctx context.Context, id entity.ProductID, deletedBy string) (int, error)
+	RestoreProduct(ctx context.Context, id entity.ProductID) (*entity.Products, int, error)
+	PurgeProducts(ctx context.Context, deletedBefore time.Time) (int, int, error)
+	AdjustStock(ctx context.Context, id entity.ProductID, data entity.AdjustStockRequest) (*entity.Products, int, error)
+	TransferStock(ctx context.Context, id entity.ProductID, data entity.TransferStockRequest) (*entity.Products, int, error)
+	ReserveStock(ctx context.Context, id entity.ProductID, quantity int) (*entity.Products, int, error)
+	ReleaseStock(ctx context.Context, id entity.ProductID, quantity int) (*entity.Products, int, error)
+	CommitReservedStock(ctx context.Context, id entity.ProductID, quantity int) (*entity.Products, []entity.Locations, int, error)
+}
+
+// ProductsIterator walks products one at a time so callers don't need to
+// hold all of them in memory. Close must be called when done.
+type ProductsIterator interface {
+	Next(ctx context.Context) bool
+	Product() *entity.Products
+	Err() error
+	Close(ctx context.Context) error
+}
@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Reservation status list.
+const (
+	StatusActive    = "active"
+	StatusConfirmed = "confirmed"
+	StatusReleased  = "released"
+	StatusExpired   = "expired"
+)
+
+type Reservations struct {
+	ID        string
+	ProductID string
+	Quantity  int
+	Status    string
+	Actor     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,17 @@
+package db
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// DB is contains functions for reservations db.
+type DB struct {
+	db           *mongo.Database
+	reservations string
+}
+
+// New to create new reservations db.
+func New(db *mongo.Database, reservations string) *DB {
+	return &DB{
+		db:           db,
+		reservations: reservations,
+	}
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/reservations/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reservations is model database for reservations.
+type Reservations struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	ProductID primitive.ObjectID `bson:"product_id"`
+	Quantity  int                `bson:"quantity"`
+	Status    string             `bson:"status"`
+	Actor     string             `bson:"actor"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at"`
+}
+
+func (reservation *Reservations) toEntity() *entity.Reservations {
+	return &entity.Reservations{
+		ID:        reservation.ID.Hex(),
+		ProductID: reservation.ProductID.Hex(),
+		Quantity:  reservation.Quantity,
+		Status:    reservation.Status,
+		Actor:     reservation.Actor,
+		ExpiresAt: reservation.ExpiresAt,
+		CreatedAt: reservation.CreatedAt,
+		UpdatedAt: reservation.UpdatedAt,
+	}
+}
+
+func toEntities(r []Reservations) []*entity.Reservations {
+	reservations := make([]*entity.Reservations, len(r))
+	for i, reservation := range r {
+		reservations[i] = reservation.toEntity()
+	}
+	return reservations
+}
+
+// EnsureIndexes to find active reservations past their expiry without
+// a collection scan.
+func (db *DB) EnsureIndexes(ctx context.Context) error {
+	_, err := db.db.Collection(db.reservations).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "status", Value: 1}, {Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("status_expires_at"),
+	})
+	return err
+}
+
+func (db *DB) CreateReservation(ctx context.Context, reservation entity.Reservations) (_ *entity.Reservations, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CreateReservation")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateReservation", time.Now(), &code)
+
+	productID, err := primitive.ObjectIDFromHex(reservation.ProductID)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+	}
+
+	data := Reservations{
+		ProductID: productID,
+		Quantity:  reservation.Quantity,
+		Status:    reservation.Status,
+		Actor:     reservation.Actor,
+		ExpiresAt: reservation.ExpiresAt,
+		CreatedAt: reservation.CreatedAt,
+		UpdatedAt: reservation.UpdatedAt,
+	}
+
+	res, err := db.db.Collection(db.reservations).InsertOne(ctx, data)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	data.ID = res.InsertedID.(primitive.ObjectID)
+
+	return data.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) GetReservationByID(ctx context.Context, id string) (_ *entity.Reservations, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetReservationByID")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetReservationByID", time.Now(), &code)
+
+	_id, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundReservation
+	}
+
+	var reservation Reservations
+	err = db.db.Collection(db.reservations).FindOne(ctx, bson.M{"_id": _id}).Decode(&reservation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, http.StatusNotFound, errors.ErrNotFoundReservation
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return reservation.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) UpdateReservationStatus(ctx context.Context, id string, from, to string) (_ *entity.Reservations, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:UpdateReservationStatus")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "UpdateReservationStatus", time.Now(), &code)
+
+	_id, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundReservation
+	}
+
+	filter := bson.M{
+		"_id":    _id,
+		"status": from,
+	}
+
+	update := bson.M{"$set": bson.M{
+		"status":     to,
+		"updated_at": time.Now(),
+	}}
+
+	var reservation Reservations
+	err = db.db.Collection(db.reservations).FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&reservation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			count, err := db.db.Collection(db.reservations).CountDocuments(ctx, bson.M{"_id": _id})
+			if err != nil {
+				return nil, http.StatusInternalServerError, errors.ErrInternalDB
+			}
+			if count == 0 {
+				return nil, http.StatusNotFound, errors.ErrNotFoundReservation
+			}
+			return nil, http.StatusConflict, errors.ErrReservationNotActive
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return reservation.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) GetExpiredReservations(ctx context.Context, now time.Time, limit int) (_ []*entity.Reservations, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetExpiredReservations")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetExpiredReservations", time.Now(), &code)
+
+	filter := bson.M{
+		"status":     entity.StatusActive,
+		"expires_at": bson.M{"$lte": now},
+	}
+
+	options := options.Find().
+		SetSort(bson.D{{Key: "expires_at", Value: 1}}).
+		SetLimit(int64(limit))
+
+	cur, err := db.db.Collection(db.reservations).Find(ctx, filter, options)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var reservations []Reservations
+	if err := cur.All(ctx, &reservations); err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return toEntities(reservations), http.StatusOK, nil
+}
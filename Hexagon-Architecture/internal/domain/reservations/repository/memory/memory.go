@@ -0,0 +1,38 @@
+package memory
+
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"hexagon-architecture/internal/domain/reservations/entity"
+)
+
+// Memory is contains functions for in-memory reservations storage.
+type Memory struct {
+	mu           sync.RWMutex
+	ids          []string
+	reservations map[string]entity.Reservations
+}
+
+// New to create new in-memory reservations storage.
+func New() *Memory {
+	return &Memory{
+		reservations: make(map[string]entity.Reservations),
+	}
+}
+
+// Snapshot to copy the reservations, returning a function putting them
+// back.
+func (m *Memory) Snapshot() func() {
+	m.mu.RLock()
+	ids := slices.Clone(m.ids)
+	reservations := maps.Clone(m.reservations)
+	m.mu.RUnlock()
+
+	return func() {
+		m.mu.Lock()
+		m.ids, m.reservations = ids, reservations
+		m.mu.Unlock()
+	}
+}
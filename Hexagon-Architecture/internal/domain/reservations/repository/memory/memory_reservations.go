@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/reservations/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Memory) CreateReservation(ctx context.Context, reservation entity.Reservations) (_ *entity.Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CreateReservation")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateReservation", time.Now(), &code)
+
+	if _, err := primitive.ObjectIDFromHex(reservation.ProductID); err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reservation.ID = primitive.NewObjectID().Hex()
+	m.reservations[reservation.ID] = reservation
+	m.ids = append(m.ids, reservation.ID)
+
+	return &reservation, http.StatusOK, nil
+}
+
+func (m *Memory) GetReservationByID(ctx context.Context, id string) (_ *entity.Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetReservationByID")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetReservationByID", time.Now(), &code)
+
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundReservation
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reservation, ok := m.reservations[id]
+	if !ok {
+		return nil, http.StatusNotFound, errors.ErrNotFoundReservation
+	}
+
+	return &reservation, http.StatusOK, nil
+}
+
+func (m *Memory) UpdateReservationStatus(ctx context.Context, id string, from, to string) (_ *entity.Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:UpdateReservationStatus")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "UpdateReservationStatus", time.Now(), &code)
+
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundReservation
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reservation, ok := m.reservations[id]
+	if !ok {
+		return nil, http.StatusNotFound, errors.ErrNotFoundReservation
+	}
+
+	if reservation.Status != from {
+		return nil, http.StatusConflict, errors.ErrReservationNotActive
+	}
+
+	reservation.Status = to
+	reservation.UpdatedAt = time.Now()
+	m.reservations[id] = reservation
+
+	return &reservation, http.StatusOK, nil
+}
+
+func (m *Memory) GetExpiredReservations(ctx context.Context, now time.Time, limit int) (_ []*entity.Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetExpiredReservations")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetExpiredReservations", time.Now(), &code)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var reservations []*entity.Reservations
+	for _, id := range m.ids {
+		reservation := m.reservations[id]
+		if reservation.Status == entity.StatusActive && !reservation.ExpiresAt.After(now) {
+			reservations = append(reservations, &reservation)
+		}
+	}
+
+	sort.SliceStable(reservations, func(i, j int) bool {
+		return reservations[i].ExpiresAt.Before(reservations[j].ExpiresAt)
+	})
+
+	if limit > 0 && len(reservations) > limit {
+		reservations = reservations[:limit]
+	}
+
+	return reservations, http.StatusOK, nil
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/reservations/entity"
+	"time"
+)
+
+// Repository contains functions for reservations domain.
+type Repository interface {
+	CreateReservation(ctx context.Context, reservation entity.Reservations) (*entity.Reservations, int, error)
+	GetReservationByID(ctx context.Context, id string) (*entity.Reservations, int, error)
+	UpdateReservationStatus(ctx context.Context, id string, from, to string) (*entity.Reservations, int, error)
+	GetExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*entity.Reservations, int, error)
+}
@@ -0,0 +1,32 @@
+package entity
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// StockMovements is one change of product stock. The ID is given by whoever
+// records it, so recording the same movement again changes nothing.
+type StockMovements struct {
+	ID            string
+	ProductID     string
+	WarehouseID   string
+	Delta         int
+	Balance       int
+	Reason        string
+	Actor         string
+	CorrelationID string
+	CreatedAt     time.Time
+}
+
+// NewID returns the id for a new movement.
+func NewID() string {
+	return primitive.NewObjectID().Hex()
+}
+
+type GetStockMovementsRequest struct {
+	ProductID string
+	Page      int
+	Limit     int
+}
@@ -0,0 +1,17 @@
+package db
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// DB is contains functions for stock movements db.
+type DB struct {
+	db             *mongo.Database
+	stockMovements string
+}
+
+// New to create new stock movements db.
+func New(db *mongo.Database, stockMovements string) *DB {
+	return &DB{
+		db:             db,
+		stockMovements: stockMovements,
+	}
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/stockmovements/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StockMovements is model database for stock movements.
+type StockMovements struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	ProductID     primitive.ObjectID `bson:"product_id"`
+	WarehouseID   string             `bson:"warehouse_id,omitempty"`
+	Delta         int                `bson:"delta"`
+	Balance       int                `bson:"balance"`
+	Reason        string             `bson:"reason"`
+	Actor         string             `bson:"actor"`
+	CorrelationID string             `bson:"correlation_id"`
+	CreatedAt     time.Time          `bson:"created_at"`
+}
+
+func (movement *StockMovements) toEntity() *entity.StockMovements {
+	return &entity.StockMovements{
+		ID:            movement.ID.Hex(),
+		ProductID:     movement.ProductID.Hex(),
+		WarehouseID:   movement.WarehouseID,
+		Delta:         movement.Delta,
+		Balance:       movement.Balance,
+		Reason:        movement.Reason,
+		Actor:         movement.Actor,
+		CorrelationID: movement.CorrelationID,
+		CreatedAt:     movement.CreatedAt,
+	}
+}
+
+func toEntities(m []StockMovements) []*entity.StockMovements {
+	movements := make([]*entity.StockMovements, len(m))
+	for i, movement := range m {
+		movements[i] = movement.toEntity()
+	}
+	return movements
+}
+
+// EnsureIndexes to create the index listing movements of a product newest
+// first. It makes the collection too, which older servers can't do within
+// a transaction.
+func (db *DB) EnsureIndexes(ctx context.Context) error {
+	_, err := db.db.Collection(db.stockMovements).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "product_id", Value: 1},
+			{Key: "created_at", Value: -1},
+			{Key: "_id", Value: -1},
+		},
+		Options: options.Index().SetName("product_id_created_at"),
+	})
+	return err
+}
+
+func (db *DB) CreateStockMovement(ctx context.Context, movement entity.StockMovements) (_ *entity.StockMovements, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CreateStockMovement")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateStockMovement", time.Now(), &code)
+
+	id, err := primitive.ObjectIDFromHex(movement.ID)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.ErrInvalidStockMovementID
+	}
+
+	productID, err := primitive.ObjectIDFromHex(movement.ProductID)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+	}
+
+	data := StockMovements{
+		ID:            id,
+		ProductID:     productID,
+		WarehouseID:   movement.WarehouseID,
+		Delta:         movement.Delta,
+		Balance:       movement.Balance,
+		Reason:        movement.Reason,
+		Actor:         movement.Actor,
+		CorrelationID: movement.CorrelationID,
+		CreatedAt:     movement.CreatedAt,
+	}
+
+	// A duplicate id is the same movement recorded before.
+	_, err = db.db.Collection(db.stockMovements).InsertOne(ctx, data)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return data.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) GetStockMovements(ctx context.Context, data entity.GetStockMovementsRequest) (_ []*entity.StockMovements, _ *utils.Pagination, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetStockMovements")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetStockMovements", time.Now(), &code)
+
+	productID, err := primitive.ObjectIDFromHex(data.ProductID)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+	}
+
+	filter := bson.M{"product_id": productID}
+
+	limit := int64(data.Limit)
+	skip := int64(data.Page*data.Limit - data.Limit)
+	options := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(skip)
+
+	cur, err := db.db.Collection(db.stockMovements).Find(ctx, filter, options)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var movements []StockMovements
+	if err := cur.All(ctx, &movements); err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	total, err := db.db.Collection(db.stockMovements).CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return toEntities(movements), &utils.Pagination{
+		Total:       int(total),
+		Limit:       data.Limit,
+		CurrentPage: data.Page,
+		LastPage:    0,
+	}, http.StatusOK, nil
+}
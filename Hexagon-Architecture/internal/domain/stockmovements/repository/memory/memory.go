@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"maps"
+	"sync"
+
+	"hexagon-architecture/internal/domain/stockmovements/entity"
+)
+
+// Memory is contains functions for in-memory stock movements storage.
+type Memory struct {
+	mu        sync.RWMutex
+	movements map[string][]entity.StockMovements
+}
+
+// New to create new in-memory stock movements storage.
+func New() *Memory {
+	return &Memory{
+		movements: make(map[string][]entity.StockMovements),
+	}
+}
+
+// Snapshot to copy the movements, returning a function putting them back.
+// Movements are only appended, so the copy can share what is there.
+func (m *Memory) Snapshot() func() {
+	m.mu.RLock()
+	movements := maps.Clone(m.movements)
+	m.mu.RUnlock()
+
+	return func() {
+		m.mu.Lock()
+		m.movements = movements
+		m.mu.Unlock()
+	}
+}
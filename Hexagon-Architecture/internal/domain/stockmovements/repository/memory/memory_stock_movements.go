@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/stockmovements/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Memory) CreateStockMovement(ctx context.Context, movement entity.StockMovements) (_ *entity.StockMovements, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CreateStockMovement")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateStockMovement", time.Now(), &code)
+
+	if _, err := primitive.ObjectIDFromHex(movement.ID); err != nil {
+		return nil, http.StatusBadRequest, errors.ErrInvalidStockMovementID
+	}
+	if _, err := primitive.ObjectIDFromHex(movement.ProductID); err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the unique _id in mongo, the same movement is kept once.
+	for _, recorded := range m.movements[movement.ProductID] {
+		if recorded.ID == movement.ID {
+			return &movement, http.StatusOK, nil
+		}
+	}
+
+	m.movements[movement.ProductID] = append(m.movements[movement.ProductID], movement)
+
+	return &movement, http.StatusOK, nil
+}
+
+func (m *Memory) GetStockMovements(ctx context.Context, data entity.GetStockMovementsRequest) (_ []*entity.StockMovements, _ *utils.Pagination, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetStockMovements")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetStockMovements", time.Now(), &code)
+
+	if _, err := primitive.ObjectIDFromHex(data.ProductID); err != nil {
+		return nil, nil, http.StatusBadRequest, errors.ErrNotFoundProduct
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Movements are appended in time order, so read them backwards for newest first.
+	all := m.movements[data.ProductID]
+
+	skip := data.Page*data.Limit - data.Limit
+	if skip < 0 {
+		skip = 0
+	}
+
+	var movements []*entity.StockMovements
+	for i := skip; i < len(all) && (data.Limit <= 0 || i < skip+data.Limit); i++ {
+		movement := all[len(all)-1-i]
+		movements = append(movements, &movement)
+	}
+
+	return movements, &utils.Pagination{
+		Total:       len(all),
+		Limit:       data.Limit,
+		CurrentPage: data.Page,
+		LastPage:    0,
+	}, http.StatusOK, nil
+}
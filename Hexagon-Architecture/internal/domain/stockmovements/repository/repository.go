@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/stockmovements/entity"
+	"hexagon-architecture/internal/utils"
+)
+
+// Repository contains functions for stock movements domain.
+// Movements are immutable, so there is no update or delete.
+type Repository interface {
+	CreateStockMovement(ctx context.Context, movement entity.StockMovements) (*entity.StockMovements, int, error)
+	GetStockMovements(ctx context.Context, data entity.GetStockMovementsRequest) ([]*entity.StockMovements, *utils.Pagination, int, error)
+}
@@ -0,0 +1,10 @@
+package entity
+
+import "time"
+
+type Warehouses struct {
+	ID        string
+	Code      string
+	Name      string
+	CreatedAt time.Time
+}
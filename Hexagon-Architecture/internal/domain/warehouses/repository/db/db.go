@@ -0,0 +1,17 @@
+package db
+
+import "go.mongodb.org/mongo-driver/mongo"
+
+// DB is contains functions for warehouses db.
+type DB struct {
+	db         *mongo.Database
+	warehouses string
+}
+
+// New to create new warehouses db.
+func New(db *mongo.Database, warehouses string) *DB {
+	return &DB{
+		db:         db,
+		warehouses: warehouses,
+	}
+}
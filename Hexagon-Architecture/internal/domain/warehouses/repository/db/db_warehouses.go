@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/warehouses/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Warehouses is model database for warehouses.
+type Warehouses struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Code      string             `bson:"code"`
+	Name      string             `bson:"name"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+func (warehouse *Warehouses) toEntity() *entity.Warehouses {
+	return &entity.Warehouses{
+		ID:        warehouse.ID.Hex(),
+		Code:      warehouse.Code,
+		Name:      warehouse.Name,
+		CreatedAt: warehouse.CreatedAt,
+	}
+}
+
+func toEntities(w []Warehouses) []*entity.Warehouses {
+	warehouses := make([]*entity.Warehouses, len(w))
+	for i, warehouse := range w {
+		warehouses[i] = warehouse.toEntity()
+	}
+	return warehouses
+}
+
+// EnsureIndexes to keep warehouse codes unique.
+func (db *DB) EnsureIndexes(ctx context.Context) error {
+	_, err := db.db.Collection(db.warehouses).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetName("code_unique").SetUnique(true),
+	})
+	return err
+}
+
+func (db *DB) CreateWarehouse(ctx context.Context, warehouse entity.Warehouses) (_ *entity.Warehouses, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:CreateWarehouse")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateWarehouse", time.Now(), &code)
+
+	data := Warehouses{
+		Code:      warehouse.Code,
+		Name:      warehouse.Name,
+		CreatedAt: warehouse.CreatedAt,
+	}
+
+	res, err := db.db.Collection(db.warehouses).InsertOne(ctx, data)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, http.StatusConflict, errors.ErrDuplicateWarehouseCode
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	data.ID = res.InsertedID.(primitive.ObjectID)
+
+	return data.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) GetWarehouseByID(ctx context.Context, id string) (_ *entity.Warehouses, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetWarehouseByID")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetWarehouseByID", time.Now(), &code)
+
+	_id, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+	}
+
+	var warehouse Warehouses
+	err = db.db.Collection(db.warehouses).FindOne(ctx, bson.M{"_id": _id}).Decode(&warehouse)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, http.StatusNotFound, errors.ErrNotFoundWarehouse
+		}
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return warehouse.toEntity(), http.StatusOK, nil
+}
+
+func (db *DB) GetWarehouses(ctx context.Context) (_ []*entity.Warehouses, code int, _ error) {
+	ctx, span := infrastructure.Tracer().Start(ctx, "db:GetWarehouses")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetWarehouses", time.Now(), &code)
+
+	cur, err := db.db.Collection(db.warehouses).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "code", Value: 1}}))
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	defer cur.Close(ctx)
+
+	var warehouses []Warehouses
+	if err := cur.All(ctx, &warehouses); err != nil {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+
+	return toEntities(warehouses), http.StatusOK, nil
+}
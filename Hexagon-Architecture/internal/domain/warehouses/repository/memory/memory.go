@@ -0,0 +1,20 @@
+package memory
+
+import (
+	"sync"
+
+	"hexagon-architecture/internal/domain/warehouses/entity"
+)
+
+// Memory is contains functions for in-memory warehouses storage.
+type Memory struct {
+	mu         sync.RWMutex
+	warehouses map[string]entity.Warehouses
+}
+
+// New to create new in-memory warehouses storage.
+func New() *Memory {
+	return &Memory{
+		warehouses: make(map[string]entity.Warehouses),
+	}
+}
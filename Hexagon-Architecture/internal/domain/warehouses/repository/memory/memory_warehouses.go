@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/warehouses/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (m *Memory) CreateWarehouse(ctx context.Context, warehouse entity.Warehouses) (_ *entity.Warehouses, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:CreateWarehouse")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "CreateWarehouse", time.Now(), &code)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Like the unique index in mongo.
+	for _, w := range m.warehouses {
+		if w.Code == warehouse.Code {
+			return nil, http.StatusConflict, errors.ErrDuplicateWarehouseCode
+		}
+	}
+
+	warehouse.ID = primitive.NewObjectID().Hex()
+	m.warehouses[warehouse.ID] = warehouse
+
+	return &warehouse, http.StatusOK, nil
+}
+
+func (m *Memory) GetWarehouseByID(ctx context.Context, id string) (_ *entity.Warehouses, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetWarehouseByID")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetWarehouseByID", time.Now(), &code)
+
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, http.StatusBadRequest, errors.ErrNotFoundWarehouse
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	warehouse, ok := m.warehouses[id]
+	if !ok {
+		return nil, http.StatusNotFound, errors.ErrNotFoundWarehouse
+	}
+
+	return &warehouse, http.StatusOK, nil
+}
+
+func (m *Memory) GetWarehouses(ctx context.Context) (_ []*entity.Warehouses, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "memory:GetWarehouses")
+	defer span.End()
+	defer infrastructure.ObserveRepository(ctx, "GetWarehouses", time.Now(), &code)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	warehouses := make([]*entity.Warehouses, 0, len(m.warehouses))
+	for _, warehouse := range m.warehouses {
+		warehouse := warehouse
+		warehouses = append(warehouses, &warehouse)
+	}
+
+	sort.Slice(warehouses, func(i, j int) bool {
+		return warehouses[i].Code < warehouses[j].Code
+	})
+
+	return warehouses, http.StatusOK, nil
+}
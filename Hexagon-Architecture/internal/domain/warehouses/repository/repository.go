@@ -0,0 +1,13 @@
+package repository
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/warehouses/entity"
+)
+
+// Repository contains functions for warehouses domain.
+type Repository interface {
+	CreateWarehouse(ctx context.Context, warehouse entity.Warehouses) (*entity.Warehouses, int, error)
+	GetWarehouseByID(ctx context.Context, id string) (*entity.Warehouses, int, error)
+	GetWarehouses(ctx context.Context) ([]*entity.Warehouses, int, error)
+}
@@ -8,21 +8,114 @@ import (
 
 // Error list.
 var (
-	ErrInvalidDBFormat      = errors.New("invalid db address")
-	ErrNotFoundBoilerplate  = errors.New("not found boilerplate")
-	ErrNotFoundProduct     = errors.New("not found product")
-	ErrInvalidRequestFormat = errors.New("invalid request format")
-	ErrInternalDB           = errors.New("internal database error")
-	ErrInternalElastic      = errors.New("internal elastic error")
-	ErrInternalCache        = errors.New("internal cache error")
-	ErrInternalServer       = errors.New("internal server error")
+	ErrInvalidDBFormat           = errors.New("invalid db address")
+	ErrNotFoundBoilerplate       = errors.New("not found boilerplate")
+	ErrNotFoundProduct           = errors.New("not found product")
+	ErrInvalidProductID          = errors.New("invalid product id")
+	ErrNotFoundDeletedProduct    = errors.New("not found deleted product")
+	ErrProductVersionMismatch    = errors.New("product version mismatch")
+	ErrInvalidIfMatch            = errors.New("invalid If-Match header")
+	ErrDuplicateSKU              = errors.New("product sku already exists")
+	ErrInvalidNameRegex          = errors.New("invalid name regex")
+	ErrNameRegexTooComplex       = errors.New("name regex is too long or complex")
+	ErrInvalidCursor             = errors.New("invalid cursor")
+	ErrInvalidBulkOperation      = errors.New("invalid bulk operation")
+	ErrTooManyBulkOperations     = errors.New("too many bulk operations")
+	ErrDuplicateBulkProduct      = errors.New("product is in more than one bulk operation")
+	ErrEmptyImportFile           = errors.New("empty import file")
+	ErrTooManyImportRows         = errors.New("too many import rows")
+	ErrDuplicateImportProduct    = errors.New("product is in more than one import row")
+	ErrInsufficientStock         = errors.New("insufficient stock")
+	ErrInsufficientReservedStock = errors.New("insufficient reserved stock")
+	ErrInvalidStockMovementID    = errors.New("invalid stock movement id")
+	ErrNotFoundWarehouse         = errors.New("not found warehouse")
+	ErrDuplicateWarehouseCode    = errors.New("warehouse code already exists")
+	ErrSameTransferLocation      = errors.New("transfer source and destination must differ")
+	ErrNotFoundReservation       = errors.New("not found reservation")
+	ErrReservationNotActive      = errors.New("reservation is not active")
+	ErrReservationExpired        = errors.New("reservation is expired")
+	ErrInvalidPatch              = errors.New("invalid patch")
+	ErrPatchTestFailed           = errors.New("patch test failed")
+	ErrNotFoundIdempotencyKey    = errors.New("not found idempotency key")
+	ErrInvalidIdempotencyKey     = errors.New("invalid idempotency key")
+	ErrIdempotencyKeyReused      = errors.New("idempotency key is already used for another request")
+	ErrIdempotencyKeyInProgress  = errors.New("request with the same idempotency key is in progress")
+	ErrUnsupportedMediaType      = errors.New("unsupported media type")
+	ErrInvalidRequestFormat      = errors.New("invalid request format")
+	ErrRequestBodyTooLarge       = errors.New("request body too large")
+	ErrInvalidAPIKey             = errors.New("invalid api key")
+	ErrUnauthorized              = errors.New("authentication required")
+	ErrForbidden                 = errors.New("not allowed")
+	ErrInternalDB                = errors.New("internal database error")
+	ErrInternalElastic           = errors.New("internal elastic error")
+	ErrInternalCache             = errors.New("internal cache error")
+	ErrInternalServer            = errors.New("internal server error")
 )
 
+// ErrInvalidRequestFormatAt is ErrInvalidRequestFormat pointing at where
+// the body went wrong. Field is empty if the problem isn't a field.
+func ErrInvalidRequestFormatAt(field string, offset int64) error {
+	if field == "" {
+		return fmt.Errorf("%w at offset %d", ErrInvalidRequestFormat, offset)
+	}
+	return fmt.Errorf("%w: field %s at offset %d", ErrInvalidRequestFormat, field, offset)
+}
+
 // ErrRequiredField is error for missing field.
 func ErrRequiredField(str string) error {
 	return fmt.Errorf("required field %s", str)
 }
 
+// ErrRequiredWithField is error for field required together with another field.
+func ErrRequiredWithField(str, with string) error {
+	return fmt.Errorf("field %s is required when %s is set", str, with)
+}
+
+// ErrRequiredUnlessField is error for field required unless another field has a value.
+func ErrRequiredUnlessField(str, field, value string) error {
+	return fmt.Errorf("field %s is required unless %s is %s", str, field, value)
+}
+
+// ErrUnknownQueryParam is error for query parameter not accepted.
+func ErrUnknownQueryParam(str string) error {
+	return fmt.Errorf("unknown query parameter %s", str)
+}
+
+// ErrInvalidQueryParam is error for query parameter in wrong format.
+func ErrInvalidQueryParam(str string) error {
+	return fmt.Errorf("invalid query parameter %s", str)
+}
+
+// ErrInvalidSortField is error for field that can't be sorted by.
+func ErrInvalidSortField(str string) error {
+	return fmt.Errorf("invalid sort field %s", str)
+}
+
+// ErrUnknownImportColumn is error for import file column not accepted.
+func ErrUnknownImportColumn(str string) error {
+	return fmt.Errorf("unknown import column %s", str)
+}
+
+// ErrDuplicateImportColumn is error for import file column given twice.
+func ErrDuplicateImportColumn(str string) error {
+	return fmt.Errorf("duplicate import column %s", str)
+}
+
+// ErrMissingImportColumn is error for required import file column not given.
+func ErrMissingImportColumn(str string) error {
+	return fmt.Errorf("missing import column %s", str)
+}
+
+// ErrInvalidImportLine is error for import file line that can't be read.
+func ErrInvalidImportLine(line int) error {
+	return fmt.Errorf("invalid import file on line %d", line)
+}
+
+// ErrInvalidImportValue is error for import value in wrong format.
+func ErrInvalidImportValue(str string) error {
+	return fmt.Errorf("invalid %s value", str)
+}
+
 // ErrGTField is error for greater than field.
 func ErrGTField(str, value string) error {
 	return fmt.Errorf("field %s must be greater than %s", str, value)
@@ -73,6 +166,21 @@ func ErrOneOfField(str, value string) error {
 	return fmt.Errorf("field %s must be one of %s", str, strings.Join(strings.Split(value, " "), "/"))
 }
 
+// ErrISO4217Field is error for ISO 4217 field.
+func ErrISO4217Field(str string) error {
+	return fmt.Errorf("field %s must be in ISO 4217 format", str)
+}
+
+// ErrMinField is error for min length field.
+func ErrMinField(str, value string) error {
+	return fmt.Errorf("field %s length must be at least %s", str, value)
+}
+
+// ErrMaxField is error for max length field.
+func ErrMaxField(str, value string) error {
+	return fmt.Errorf("field %s length must be at most %s", str, value)
+}
+
 // ErrNumericField is error for numeric field.
 func ErrNumericField(str string) error {
 	return fmt.Errorf("field %s must contain number only", str)
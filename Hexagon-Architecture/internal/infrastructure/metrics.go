@@ -0,0 +1,123 @@
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// durationBuckets are histogram buckets in seconds, from fast lookups to
+// slow exports.
+var durationBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// sizeBuckets are histogram buckets in bytes, up to the 1MB JSON body
+// limit and the 4MB default server body limit imports are held to.
+var sizeBuckets = []float64{0, 128, 512, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+type instruments struct {
+	httpDuration       metric.Float64Histogram
+	httpRequestSize    metric.Int64Histogram
+	httpResponseSize   metric.Int64Histogram
+	repositoryDuration metric.Float64Histogram
+	serviceDuration    metric.Float64Histogram
+}
+
+var (
+	instrumentsOnce sync.Once
+	instrumentsSet  instruments
+)
+
+// Meter returns the meter of the service.
+func Meter() metric.Meter {
+	return otel.Meter("store-service")
+}
+
+// MetricsHandler returns the handler serving metrics in Prometheus format.
+func MetricsHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// getInstruments creates the instruments on first use. Creating one only
+// fails for an invalid name, so errors are ignored and the no-op instrument
+// returned with them is used.
+func getInstruments() *instruments {
+	instrumentsOnce.Do(func() {
+		meter := Meter()
+
+		instrumentsSet.httpDuration, _ = meter.Float64Histogram("http.server.request.duration",
+			metric.WithUnit("s"),
+			metric.WithDescription("Duration of HTTP requests."),
+			metric.WithExplicitBucketBoundaries(durationBuckets...),
+		)
+		instrumentsSet.httpRequestSize, _ = meter.Int64Histogram("http.server.request.body.size",
+			metric.WithUnit("By"),
+			metric.WithDescription("Size of HTTP request bodies."),
+			metric.WithExplicitBucketBoundaries(sizeBuckets...),
+		)
+		instrumentsSet.httpResponseSize, _ = meter.Int64Histogram("http.server.response.body.size",
+			metric.WithUnit("By"),
+			metric.WithDescription("Size of HTTP response bodies."),
+			metric.WithExplicitBucketBoundaries(sizeBuckets...),
+		)
+		instrumentsSet.repositoryDuration, _ = meter.Float64Histogram("repository.operation.duration",
+			metric.WithUnit("s"),
+			metric.WithDescription("Duration of repository operations."),
+			metric.WithExplicitBucketBoundaries(durationBuckets...),
+		)
+		instrumentsSet.serviceDuration, _ = meter.Float64Histogram("service.operation.duration",
+			metric.WithUnit("s"),
+			metric.WithDescription("Duration of service operations."),
+			metric.WithExplicitBucketBoundaries(durationBuckets...),
+		)
+	})
+	return &instrumentsSet
+}
+
+// ObserveHTTPRequest to record a served request. route is the route
+// pattern, not the path, to keep the number of series bounded. A negative
+// responseSize means the size is unknown and isn't recorded.
+func ObserveHTTPRequest(ctx context.Context, method, route string, status int, duration time.Duration, requestSize, responseSize int) {
+	i := getInstruments()
+	attrs := metric.WithAttributes(
+		attribute.String("http.request.method", method),
+		attribute.String("http.route", route),
+		attribute.String("http.response.status_code", strconv.Itoa(status)),
+	)
+
+	i.httpDuration.Record(ctx, duration.Seconds(), attrs)
+	i.httpRequestSize.Record(ctx, int64(requestSize), attrs)
+	if responseSize >= 0 {
+		i.httpResponseSize.Record(ctx, int64(responseSize), attrs)
+	}
+}
+
+// ObserveRepository to record how long a repository operation took since
+// startTime and the status code it ended with. Meant to be deferred at the
+// start of the operation with its named code result, which is read once the
+// operation returns.
+func ObserveRepository(ctx context.Context, operation string, startTime time.Time, code *int) {
+	getInstruments().repositoryDuration.Record(ctx, time.Since(startTime).Seconds(),
+		metric.WithAttributes(
+			attribute.String("operation", operation),
+			attribute.String("status_code", strconv.Itoa(*code)),
+		),
+	)
+}
+
+// ObserveService to record how long a service operation took since
+// startTime and the status code it ended with, like ObserveRepository.
+func ObserveService(ctx context.Context, operation string, startTime time.Time, code *int) {
+	getInstruments().serviceDuration.Record(ctx, time.Since(startTime).Seconds(),
+		metric.WithAttributes(
+			attribute.String("operation", operation),
+			attribute.String("status_code", strconv.Itoa(*code)),
+		),
+	)
+}
@@ -3,13 +3,14 @@ package infrastructure
 import (
 	"context"
 	"log"
+	"sync"
 	"time"
 
 	"errors"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/instrumentation"
 	"go.opentelemetry.io/otel/sdk/metric"
@@ -97,8 +98,10 @@ func newTraceProvider(ctx context.Context, endpoint string, res *resource.Resour
 		log.Fatalf("new otlp trace grpc exporter failed: %v", err)
 	}
 
+	exporterStatus = &statusExporter{SpanExporter: traceExporter}
+
 	traceProvider := trace.NewTracerProvider(
-		trace.WithBatcher(traceExporter,
+		trace.WithBatcher(exporterStatus,
 			// Default is 5s. Set to 1s for demonstrative purposes.
 			trace.WithBatchTimeout(time.Second)),
 		trace.WithResource(res),
@@ -109,8 +112,44 @@ func newTraceProvider(ctx context.Context, endpoint string, res *resource.Resour
 	return traceProvider, nil
 }
 
+// statusExporter keeps the result of the last span export so readiness
+// can tell whether traces still reach the collector.
+type statusExporter struct {
+	trace.SpanExporter
+
+	mu  sync.Mutex
+	err error
+}
+
+func (e *statusExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+
+	e.mu.Lock()
+	e.err = err
+	e.mu.Unlock()
+
+	return err
+}
+
+var exporterStatus *statusExporter
+
+// CheckExporter returns the error of the last trace export, nil if it
+// succeeded or nothing was exported yet.
+func CheckExporter(ctx context.Context) error {
+	if exporterStatus == nil {
+		return errors.New("trace exporter is not set up")
+	}
+
+	exporterStatus.mu.Lock()
+	defer exporterStatus.mu.Unlock()
+
+	return exporterStatus.err
+}
+
+// newMeterProvider exports metrics through a Prometheus registry, served
+// by MetricsHandler.
 func newMeterProvider(ctx context.Context, endpoint string, res *resource.Resource) (*metric.MeterProvider, error) {
-	metricExporter, err := stdoutmetric.New()
+	metricExporter, err := prometheus.New()
 	if err != nil {
 		return nil, err
 	}
@@ -121,7 +160,7 @@ func newMeterProvider(ctx context.Context, endpoint string, res *resource.Resour
 			metric.Instrument{Scope: instrumentation.Scope{Name: "go.opentelemetry.io/contrib/google.golang.org/grpc/otelgrpc"}},
 			metric.Stream{Aggregation: metric.AggregationDrop{}},
 		)),
-		metric.WithReader(metric.NewPeriodicReader(metricExporter)))
+		metric.WithReader(metricExporter))
 	otel.SetMeterProvider(meterProvider)
 	return meterProvider, nil
 }
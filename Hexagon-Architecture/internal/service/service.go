@@ -2,28 +2,144 @@ package service
 
 import (
 	"context"
+	idempotencyKeysRepo "hexagon-architecture/internal/domain/idempotencykeys/repository"
+	productsEntity "hexagon-architecture/internal/domain/products/entity"
 	productsRepo "hexagon-architecture/internal/domain/products/repository"
+	reservationsRepo "hexagon-architecture/internal/domain/reservations/repository"
+	stockMovementsRepo "hexagon-architecture/internal/domain/stockmovements/repository"
+	warehousesRepo "hexagon-architecture/internal/domain/warehouses/repository"
+	"hexagon-architecture/internal/errors"
 	"hexagon-architecture/internal/utils"
+	"hexagon-architecture/pkg/cache"
+	"hexagon-architecture/pkg/transaction"
+	"log/slog"
+	"net/http"
+	"time"
 )
 
 // Service contains functions for service.
 type Service interface {
-	GetProduct(ctx context.Context, id string) (*Products, int, error)
+	GetProduct(ctx context.Context, id productsEntity.ProductID) (*Products, int, error)
 	GetProducts(ctx context.Context, req GetProductsRequest) ([]*Products, *utils.Pagination, int, error)
+	GetProductsByCursor(ctx context.Context, req GetProductsRequest, cursor string) ([]*Products, *utils.Cursor, int, error)
+	ExportProducts(ctx context.Context, req GetProductsRequest) (*ProductsIterator, int, error)
+	SearchProducts(ctx context.Context, req SearchProductsRequest) (*SearchProductsResponse, int, error)
+	SuggestProducts(ctx context.Context, req SuggestProductsRequest) ([]*Suggestion, int, error)
 	CreateProduct(ctx context.Context, data CreateProductRequest) (*Products, int, error)
-	UpdateProduct(ctx context.Context, id string, updateData UpdateProductRequest) (*Products, int, error)
-	DeleteProduct(ctx context.Context, id string) (int, error)
+	BulkProducts(ctx context.Context, req BulkProductsRequest) ([]*BulkProductResult, int, error)
+	ImportProducts(ctx context.Context, req ImportProductsRequest) (*ImportProductsResponse, int, error)
+	UpdateProduct(ctx context.Context, id productsEntity.ProductID, updateData UpdateProductRequest) (*Products, int, error)
+	ReplaceProduct(ctx context.Context, id productsEntity.ProductID, data ReplaceProductRequest) (*Products, int, error)
+	PatchProduct(ctx context.Context, id productsEntity.ProductID, req PatchProductRequest) (*Products, int, error)
+	DeleteProduct(ctx context.Context, id productsEntity.ProductID) (int, error)
+	RestoreProduct(ctx context.Context, id productsEntity.ProductID) (*Products, int, error)
+	PurgeProducts(ctx context.Context) (*PurgeProductsResponse, int, error)
+	GetProductStats(ctx context.Context) (*ProductStats, int, error)
+	AdjustStock(ctx context.Context, id productsEntity.ProductID, data AdjustStockRequest) (*Products, int, error)
+	TransferStock(ctx context.Context, id productsEntity.ProductID, data TransferStockRequest) (*Products, int, error)
+	GetStockMovements(ctx context.Context, productID productsEntity.ProductID, req GetStockMovementsRequest) ([]*StockMovements, *utils.Pagination, int, error)
+
+	ReserveStock(ctx context.Context, productID productsEntity.ProductID, data ReserveStockRequest) (*Reservations, int, error)
+	GetReservation(ctx context.Context, id string) (*Reservations, int, error)
+	ConfirmReservation(ctx context.Context, id string) (*Reservations, int, error)
+	ReleaseReservation(ctx context.Context, id string) (*Reservations, int, error)
+	ExpireReservations(ctx context.Context) (int, int, error)
+
+	CreateWarehouse(ctx context.Context, data CreateWarehouseRequest) (*Warehouses, int, error)
+	GetWarehouses(ctx context.Context) ([]*Warehouses, int, error)
+
+	StartIdempotentRequest(ctx context.Context, key string, requestHash string) (*IdempotentResponse, int, error)
+	FinishIdempotentRequest(ctx context.Context, key string, response IdempotentResponse) (int, error)
+	CancelIdempotentRequest(ctx context.Context, key string) (int, error)
+}
+
+// Config is service config.
+type Config struct {
+	PurgeRetention time.Duration
+	ReservationTTL time.Duration
+
+	// SuggestCacheTTL is how long product suggestions of a prefix are
+	// cached. Zero disables the cache.
+	SuggestCacheTTL time.Duration
+
+	// StatsCacheTTL is how long product stats are cached, so metric
+	// scrapes don't count every product each time. Zero disables the
+	// cache.
+	StatsCacheTTL time.Duration
+
+	// IdempotencyKeyTTL is how long a response is replayed for retries
+	// with the same Idempotency-Key.
+	IdempotencyKeyTTL time.Duration
 }
 
 type service struct {
-	products productsRepo.Repository
+	products        productsRepo.Repository
+	stockMovements  stockMovementsRepo.Repository
+	reservations    reservationsRepo.Repository
+	warehouses      warehousesRepo.Repository
+	idempotencyKeys idempotencyKeysRepo.Repository
+	transaction     transaction.Transaction
+	suggestions     cache.Cache[[]*Suggestion]
+	stats           cache.Cache[*ProductStats]
+	cfg             Config
 }
 
+// maxSuggestCacheEntries is how many prefixes the suggestion cache holds.
+const maxSuggestCacheEntries = 1000
+
 // New to create new service.
 func New(
 	products productsRepo.Repository,
+	stockMovements stockMovementsRepo.Repository,
+	reservations reservationsRepo.Repository,
+	warehouses warehousesRepo.Repository,
+	idempotencyKeys idempotencyKeysRepo.Repository,
+	transaction transaction.Transaction,
+	cfg Config,
 ) Service {
-	return &service{
-		products: products,
+	s := &service{
+		products:        products,
+		stockMovements:  stockMovements,
+		reservations:    reservations,
+		warehouses:      warehouses,
+		idempotencyKeys: idempotencyKeys,
+		transaction:     transaction,
+		cfg:             cfg,
+	}
+
+	if cfg.SuggestCacheTTL > 0 {
+		s.suggestions = cache.New[[]*Suggestion](cache.Config{
+			TTL:        cfg.SuggestCacheTTL,
+			MaxEntries: maxSuggestCacheEntries,
+		})
+	}
+
+	if cfg.StatsCacheTTL > 0 {
+		s.stats = cache.New[*ProductStats](cache.Config{
+			TTL:        cfg.StatsCacheTTL,
+			MaxEntries: 1,
+		})
+	}
+
+	return s
+}
+
+// inTransaction to run fn in a transaction, which is rolled back when fn
+// fails. It returns the code and error of fn, or a database error if the
+// transaction itself fails.
+func (s *service) inTransaction(ctx context.Context, fn func(ctx context.Context) (int, error)) (int, error) {
+	var code int
+	var fnErr error
+	err := s.transaction.Run(ctx, func(ctx context.Context) error {
+		code, fnErr = fn(ctx)
+		return fnErr
+	})
+	if fnErr != nil {
+		return code, fnErr
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to run transaction", slog.String("error", err.Error()))
+		return http.StatusInternalServerError, errors.ErrInternalDB
 	}
+	return code, nil
 }
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/idempotencykeys/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+	"unicode"
+)
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key accepted.
+const maxIdempotencyKeyLength = 255
+
+// IdempotentResponse is a saved response to replay for a retried request.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	ETag        string
+	Body        []byte
+}
+
+// StartIdempotentRequest to claim key for a request whose method, path,
+// body and so on hash to requestHash. It returns the saved response if the
+// same request already finished, or nil when the request should run and
+// then be finished or cancelled.
+func (s *service) StartIdempotentRequest(ctx context.Context, key string, requestHash string) (_ *IdempotentResponse, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:StartIdempotentRequest")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "StartIdempotentRequest", time.Now(), &code)
+
+	if !validIdempotencyKey(key) {
+		return nil, http.StatusBadRequest, errors.ErrInvalidIdempotencyKey
+	}
+
+	now := time.Now()
+	existing, code, err := s.idempotencyKeys.CreateKey(ctx, entity.IdempotencyKeys{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.cfg.IdempotencyKeyTTL),
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	switch {
+	case existing == nil:
+		return nil, http.StatusOK, nil
+	case existing.RequestHash != requestHash:
+		return nil, http.StatusUnprocessableEntity, errors.ErrIdempotencyKeyReused
+	case !existing.Completed:
+		return nil, http.StatusConflict, errors.ErrIdempotencyKeyInProgress
+	}
+
+	return &IdempotentResponse{
+		Status:      existing.Status,
+		ContentType: existing.ContentType,
+		ETag:        existing.ETag,
+		Body:        existing.Body,
+	}, http.StatusOK, nil
+}
+
+// FinishIdempotentRequest to save the response of a request started with
+// StartIdempotentRequest.
+func (s *service) FinishIdempotentRequest(ctx context.Context, key string, response IdempotentResponse) (code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:FinishIdempotentRequest")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "FinishIdempotentRequest", time.Now(), &code)
+
+	return s.idempotencyKeys.CompleteKey(ctx, key, entity.IdempotencyKeys{
+		Status:      response.Status,
+		ContentType: response.ContentType,
+		ETag:        response.ETag,
+		Body:        response.Body,
+	})
+}
+
+// CancelIdempotentRequest to free key of a request that failed in a way
+// worth retrying, so the retry runs again instead of being rejected.
+func (s *service) CancelIdempotentRequest(ctx context.Context, key string) (code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:CancelIdempotentRequest")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "CancelIdempotentRequest", time.Now(), &code)
+
+	return s.idempotencyKeys.DeleteKey(ctx, key)
+}
+
+func validIdempotencyKey(key string) bool {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentRequest(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, withConfig(service.Config{IdempotencyKeyTTL: time.Hour}))
+
+	saved, code, err := s.StartIdempotentRequest(ctx, "key-1", "hash-1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, saved, "first request runs")
+
+	_, code, err = s.StartIdempotentRequest(ctx, "key-1", "hash-1")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrIdempotencyKeyInProgress, err)
+
+	response := service.IdempotentResponse{Status: http.StatusOK, ContentType: "application/json", ETag: `"1"`, Body: []byte(`{"status":200}`)}
+	_, err = s.FinishIdempotentRequest(ctx, "key-1", response)
+	assert.NoError(t, err)
+
+	saved, code, err = s.StartIdempotentRequest(ctx, "key-1", "hash-1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, &response, saved, "retry gets the first response")
+
+	_, code, err = s.StartIdempotentRequest(ctx, "key-1", "hash-2")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Equal(t, errors.ErrIdempotencyKeyReused, err)
+
+	_, _, err = s.StartIdempotentRequest(ctx, "key-2", "hash-1")
+	assert.NoError(t, err)
+	_, err = s.CancelIdempotentRequest(ctx, "key-2")
+	assert.NoError(t, err)
+	saved, _, err = s.StartIdempotentRequest(ctx, "key-2", "hash-1")
+	assert.NoError(t, err)
+	assert.Nil(t, saved, "cancelled request runs again")
+
+	for _, key := range []string{strings.Repeat("k", 256), "key\n"} {
+		_, code, err = s.StartIdempotentRequest(ctx, key, "hash-1")
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, errors.ErrInvalidIdempotencyKey, err)
+	}
+}
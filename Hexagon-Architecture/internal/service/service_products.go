@@ -3,126 +3,625 @@ package service
 import (
 	"context"
 	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
 	"hexagon-architecture/internal/infrastructure"
 	"hexagon-architecture/internal/utils"
 	"net/http"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"time"
 )
 
 type Products struct {
-	ID     string `json:"id"`
-	Name   string `json:"name_product"`
-	Stock  int `json:"stock"`
+	ID          string              `json:"id"`
+	SKU         string              `json:"sku"`
+	Name        string              `json:"name_product"`
+	Description string              `json:"description"`
+	Category    string              `json:"category"`
+	Tags        []string            `json:"tags"`
+	Price       int64               `json:"price"`
+	Currency    string              `json:"currency"`
+	Stock       int                 `json:"stock"`
+	Reserved    int                 `json:"reserved"`
+	Available   int                 `json:"available"`
+	Unallocated int                 `json:"unallocated"`
+	Locations   []*ProductLocations `json:"locations"`
+	Version     int                 `json:"version"`
+	CreatedAt   time.Time           `json:"created_at"`
+}
+
+type ProductLocations struct {
+	WarehouseID   string `json:"warehouse_id"`
+	WarehouseName string `json:"warehouse_name,omitempty"`
+	Stock         int    `json:"stock"`
+}
+
+func productFromEntity(product *entity.Products) *Products {
+	locations := make([]*ProductLocations, len(product.Locations))
+	for i, location := range product.Locations {
+		locations[i] = &ProductLocations{
+			WarehouseID: location.WarehouseID,
+			Stock:       location.Stock,
+		}
+	}
+
+	return &Products{
+		ID:          product.ID,
+		SKU:         product.SKU,
+		Name:        product.Name,
+		Description: product.Description,
+		Category:    product.Category,
+		Tags:        product.Tags,
+		Price:       product.Price,
+		Currency:    product.Currency,
+		Stock:       product.Stock,
+		Reserved:    product.Reserved,
+		Available:   product.Stock - product.Reserved,
+		Unallocated: product.Unallocated(),
+		Locations:   locations,
+		Version:     product.Version,
+		CreatedAt:   product.CreatedAt,
+	}
+}
+
+func productsFromEntities(products []*entity.Products) []*Products {
+	productsDTO := make([]*Products, len(products))
+	for i, product := range products {
+		productsDTO[i] = productFromEntity(product)
+	}
+	return productsDTO
 }
 
 type GetProductsRequest struct {
-	Page  int
-	Limit int
-	Name  string
+	Page     int
+	Limit    int
+	Name     string `mod:"trim"`
+	Match    string `validate:"omitempty,oneof=contains prefix exact regex" mod:"trim,lcase"`
+	SKU      string `mod:"trim"`
+	Category string `mod:"trim,lcase"`
+	Tag      string `mod:"trim,lcase"`
+	Currency string `validate:"omitempty,iso4217" mod:"trim,ucase"`
+	StockLT  *int
+	StockLTE *int
+	StockGT  *int
+	StockGTE *int
+	InStock  *bool
+
+	// Sort is comma separated fields, prefixed with - for descending.
+	Sort string `mod:"no_space"`
 }
 
-func (s *service) GetProduct(ctx context.Context, id string) (*Products, int, error) {
+func (s *service) GetProduct(ctx context.Context, productID entity.ProductID) (_ *Products, code int, _ error) {
 	_, span := infrastructure.Tracer().Start(ctx, "service:GetProduct")
 	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetProduct", time.Now(), &code)
 
-	product, code, err := s.products.GetProductByID(ctx, id)
+	product, code, err := s.products.GetProductByID(ctx, productID)
 	if err != nil {
 
 		return nil, code, err
 	}
 
-	return &Products{
-		ID:     product.ID,
-		Name:   product.Name,
-		Stock:   product.Stock,
-	}, code, nil
+	productDTO := productFromEntity(product)
+
+	if len(productDTO.Locations) > 0 {
+		warehouses, code, err := s.warehouses.GetWarehouses(ctx)
+		if err != nil {
+			return nil, code, err
+		}
+
+		names := make(map[string]string, len(warehouses))
+		for _, warehouse := range warehouses {
+			names[warehouse.ID] = warehouse.Name
+		}
+
+		for _, location := range productDTO.Locations {
+			location.WarehouseName = names[location.WarehouseID]
+		}
+	}
+
+	return productDTO, code, nil
 }
 
-func (s *service) GetProducts(ctx context.Context, req GetProductsRequest) ([]*Products, *utils.Pagination, int, error) {
-	_, span := infrastructure.Tracer().Start(ctx, "service:GetCompanies")
+func (s *service) GetProducts(ctx context.Context, req GetProductsRequest) (_ []*Products, _ *utils.Pagination, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:GetProducts")
 	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetProducts", time.Now(), &code)
 
-	products, pagination, code, err := s.products.GetCompanies(ctx, entity.GetProductsRequest{
-		Page:  req.Page,
-		Limit: req.Limit,
-		Name:  req.Name,
-	})
+	query, err := req.toEntity()
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	products, pagination, code, err := s.products.GetCompanies(ctx, query)
 	if err != nil {
 		return nil, nil, code, err
 	}
 
-	productsDTO := make([]*Products, len(products))
-	for i, product := range products {
-		productDTO := &Products{
-			ID:         product.ID,
-			Name:       product.Name,
-			Stock: product.Stock,
+	return productsFromEntities(products), pagination, code, nil
+}
+
+// defaultCursorLimit is the page size of GetProductsByCursor when none
+// is given.
+const defaultCursorLimit = 5
+
+// GetProductsByCursor returns products after cursor, which is empty for
+// the first page. Unlike GetProducts there is no page or total.
+func (s *service) GetProductsByCursor(ctx context.Context, req GetProductsRequest, cursor string) (_ []*Products, _ *utils.Cursor, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:GetProductsByCursor")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetProductsByCursor", time.Now(), &code)
+
+	if req.Limit <= 0 {
+		req.Limit = defaultCursorLimit
+	}
+
+	query, err := req.toEntity()
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+
+	products, next, code, err := s.products.GetProductsByCursor(ctx, query, cursor)
+	if err != nil {
+		return nil, nil, code, err
+	}
+
+	return productsFromEntities(products), &utils.Cursor{
+		NextCursor: next,
+		Limit:      req.Limit,
+	}, code, nil
+}
+
+func (req GetProductsRequest) toEntity() (entity.GetProductsRequest, error) {
+	if err := utils.Validate(&req); err != nil {
+		return entity.GetProductsRequest{}, err
+	}
+
+	if req.Match == entity.MatchRegex {
+		if err := checkNameRegex(req.Name); err != nil {
+			return entity.GetProductsRequest{}, err
 		}
-		productsDTO[i] = productDTO
 	}
 
-	return productsDTO, pagination, code, nil
+	sort, err := parseSort(req.Sort)
+	if err != nil {
+		return entity.GetProductsRequest{}, err
+	}
+
+	return entity.GetProductsRequest{
+		Page:     req.Page,
+		Limit:    req.Limit,
+		Name:     req.Name,
+		Match:    req.Match,
+		SKU:      req.SKU,
+		Category: req.Category,
+		Tag:      req.Tag,
+		Currency: req.Currency,
+		StockLT:  req.StockLT,
+		StockLTE: req.StockLTE,
+		StockGT:  req.StockGT,
+		StockGTE: req.StockGTE,
+		InStock:  req.InStock,
+		Sort:     sort,
+	}, nil
+}
+
+// parseSort to turn sort query like "name,-stock" into sort fields.
+func parseSort(str string) ([]entity.SortField, error) {
+	if str == "" {
+		return nil, nil
+	}
+
+	var fields []entity.SortField
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(str, ",") {
+		sortField := entity.SortField{Field: strings.TrimPrefix(field, "-")}
+		sortField.Desc = sortField.Field != field
+
+		switch sortField.Field {
+		case entity.SortName, entity.SortStock, entity.SortCreatedAt:
+		default:
+			return nil, errors.ErrInvalidSortField(field)
+		}
+		if seen[sortField.Field] {
+			return nil, errors.ErrInvalidSortField(field)
+		}
+		seen[sortField.Field] = true
+
+		fields = append(fields, sortField)
+	}
+
+	return fields, nil
 }
 
+// Name regex search limits. Nested repetition and alternation inside a
+// repetition are rejected outright since that is what makes a backtracking
+// engine blow up, e.g. (a+)+ or (a|aa)*. Alternatives of one character
+// each are parsed into a class, so (a|b)* is still fine.
+const (
+	maxNameRegexLength  = 64
+	maxNameRegexRepeats = 4
+)
+
+// checkNameRegex to make sure a user supplied name regex is cheap to run.
+func checkNameRegex(pattern string) error {
+	if len(pattern) > maxNameRegexLength {
+		return errors.ErrNameRegexTooComplex
+	}
+
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return errors.ErrInvalidNameRegex
+	}
+
+	var repeats int
+	var walk func(re *syntax.Regexp, inRepeat bool) bool
+	walk = func(re *syntax.Regexp, inRepeat bool) bool {
+		switch re.Op {
+		case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+			if inRepeat {
+				return false
+			}
+			repeats++
+			inRepeat = true
+		case syntax.OpAlternate:
+			if inRepeat {
+				return false
+			}
+		}
+		for _, sub := range re.Sub {
+			if !walk(sub, inRepeat) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !walk(re, false) || repeats > maxNameRegexRepeats {
+		return errors.ErrNameRegexTooComplex
+	}
+
+	return nil
+}
+
+// Price is in minor units of Currency, e.g. cents for USD.
 type CreateProductRequest struct {
-	Name string `json:"name_product" validate:"required"`
-	Stock int `json:"stock" validate:"required"`
+	SKU         string   `json:"sku" validate:"required,max=64" mod:"trim"`
+	Name        string   `json:"name_product" validate:"required"`
+	Description string   `json:"description" validate:"max=2000" mod:"trim"`
+	Category    string   `json:"category" validate:"max=64" mod:"trim,lcase"`
+	Tags        []string `json:"tags" validate:"max=20,dive,required,max=32" mod:"dive,trim,lcase"`
+	Price       int64    `json:"price" validate:"gte=0"`
+	Currency    string   `json:"currency" validate:"required_with=Price,omitempty,iso4217" mod:"trim,ucase"`
+	Stock       *int     `json:"stock" validate:"required,gte=0"`
 }
 
-func (s *service) CreateProduct(ctx context.Context, data CreateProductRequest) (*Products, int, error) {
+func (s *service) CreateProduct(ctx context.Context, data CreateProductRequest) (_ *Products, code int, _ error) {
 	_, span := infrastructure.Tracer().Start(ctx, "service:CreateProduct")
 	defer span.End()
+	defer infrastructure.ObserveService(ctx, "CreateProduct", time.Now(), &code)
 
 	if err := utils.Validate(&data); err != nil {
 		return nil, http.StatusBadRequest, err
 	}
 
-	product, code, err := s.products.CreateProduct(ctx, entity.Products{
-		Name: data.Name,
-		Stock : data.Stock,
+	var product *entity.Products
+	code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+		var code int
+		var err error
+		product, code, err = s.products.CreateProduct(ctx, data.toEntity())
+		if err != nil {
+			return code, err
+		}
+
+		return s.recordStockMovement(ctx, product, "", product.Stock, "create")
 	})
 	if err != nil {
 		return nil, code, err
 	}
 
-	return &Products{
-		ID:   product.ID,
-		Name: product.Name,
-		Stock: product.Stock,
-	}, code, nil
+	return productFromEntity(product), code, nil
+}
+
+func (r CreateProductRequest) toEntity() entity.Products {
+	return entity.Products{
+		SKU:         r.SKU,
+		Name:        r.Name,
+		Description: r.Description,
+		Category:    r.Category,
+		Tags:        r.Tags,
+		Price:       r.Price,
+		Currency:    r.Currency,
+		Stock:       *r.Stock,
+	}
 }
 
+// maxUpdateAttempts is how many times an unconditional stock update is
+// retried when the product keeps changing underneath it.
+const maxUpdateAttempts = 3
+
 type UpdateProductRequest struct {
-	Name  string `json:"name_product"`
-	Stock *int   `json:"stock"`
+	SKU         *string  `json:"sku" validate:"omitempty,min=1,max=64" mod:"trim"`
+	Name        *string  `json:"name_product" validate:"omitempty,min=1"`
+	Description *string  `json:"description" validate:"omitempty,max=2000" mod:"trim"`
+	Category    *string  `json:"category" validate:"omitempty,max=64" mod:"trim,lcase"`
+	Tags        []string `json:"tags" validate:"max=20,dive,required,max=32" mod:"dive,trim,lcase"`
+	Price       *int64   `json:"price" validate:"omitempty,gte=0"`
+	Currency    *string  `json:"currency" validate:"omitempty,iso4217" mod:"trim,ucase"`
+	Stock       *int     `json:"stock"`
+
+	// Versions are the product versions the client expects to update,
+	// any of them will do. Empty means update regardless of the current
+	// version.
+	Versions []int `json:"-"`
 }
 
-func (s *service) UpdateProduct(ctx context.Context, id string, updateData UpdateProductRequest) (*Products, int, error) {
+func (s *service) UpdateProduct(ctx context.Context, productID entity.ProductID, updateData UpdateProductRequest) (_ *Products, code int, _ error) {
 	_, span := infrastructure.Tracer().Start(ctx, "service:UpdateProduct")
 	defer span.End()
+	defer infrastructure.ObserveService(ctx, "UpdateProduct", time.Now(), &code)
 
-	product, code, err := s.products.UpdateProduct(ctx, id, entity.UpdateProductsRequest{
-		Name:  updateData.Name,
-		Stock: updateData.Stock,
-	})
-	if err != nil {
-		return nil, code, err
+	if err := utils.Validate(&updateData); err != nil {
+		return nil, http.StatusBadRequest, err
 	}
 
-	return &Products{
-		ID:    product.ID,
-		Name:  product.Name,
-		Stock: product.Stock,
-	}, code, nil
+	return s.updateProduct(ctx, productID, updateData)
 }
 
-func (s *service) DeleteProduct(ctx context.Context, id string) (int, error) {
+// updateProduct to write an already validated update.
+func (s *service) updateProduct(ctx context.Context, id entity.ProductID, updateData UpdateProductRequest) (*Products, int, error) {
+	if updateData.Stock == nil && len(updateData.Versions) <= 1 {
+		var version *int
+		if len(updateData.Versions) == 1 {
+			version = &updateData.Versions[0]
+		}
+
+		product, code, err := s.products.UpdateProduct(ctx, id, updateData.toEntity(version))
+		if err != nil {
+			return nil, code, err
+		}
+
+		return productFromEntity(product), code, nil
+	}
+
+	// Stock overwrite goes to the ledger as a delta, and a list of versions
+	// can only be checked against the product as read, so the update is
+	// pinned to the version read. A concurrent change just means reading
+	// and checking again.
+	for attempt := 1; ; attempt++ {
+		var product *entity.Products
+		var changed bool
+		code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+			current, code, err := s.products.GetProductByID(ctx, id)
+			if err != nil {
+				return code, err
+			}
+
+			if len(updateData.Versions) > 0 && !slices.Contains(updateData.Versions, current.Version) {
+				return http.StatusPreconditionFailed, errors.ErrProductVersionMismatch
+			}
+
+			product, code, err = s.products.UpdateProduct(ctx, id, updateData.toEntity(&current.Version))
+			changed = code == http.StatusPreconditionFailed
+			if err != nil {
+				return code, err
+			}
+
+			return s.recordStockMovement(ctx, product, "", product.Stock-current.Stock, "update")
+		})
+		if changed && attempt < maxUpdateAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, code, err
+		}
+
+		return productFromEntity(product), code, nil
+	}
+}
+
+func (r UpdateProductRequest) toEntity(version *int) entity.UpdateProductsRequest {
+	return entity.UpdateProductsRequest{
+		Name:        r.Name,
+		SKU:         r.SKU,
+		Description: r.Description,
+		Category:    r.Category,
+		Tags:        r.Tags,
+		Price:       r.Price,
+		Currency:    r.Currency,
+		Stock:       r.Stock,
+		Version:     version,
+	}
+}
+
+func (s *service) DeleteProduct(ctx context.Context, productID entity.ProductID) (code int, _ error) {
 	_, span := infrastructure.Tracer().Start(ctx, "service:DeleteProduct")
 	defer span.End()
+	defer infrastructure.ObserveService(ctx, "DeleteProduct", time.Now(), &code)
 
-	code, err := s.products.DeleteProduct(ctx, id)
+	code, err := s.products.DeleteProduct(ctx, productID, utils.GetActor(ctx))
 	if err != nil {
 		return code, err
 	}
 
 	return http.StatusOK, nil
-}
\ No newline at end of file
+}
+
+func (s *service) RestoreProduct(ctx context.Context, productID entity.ProductID) (_ *Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:RestoreProduct")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "RestoreProduct", time.Now(), &code)
+
+	product, code, err := s.products.RestoreProduct(ctx, productID)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return productFromEntity(product), code, nil
+}
+
+// statsCacheKey is the only key of the stats cache.
+const statsCacheKey = "stats"
+
+// ProductStats is product counts reported as business metrics.
+type ProductStats struct {
+	Total      int `json:"total"`
+	OutOfStock int `json:"out_of_stock"`
+}
+
+func (s *service) GetProductStats(ctx context.Context) (_ *ProductStats, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:GetProductStats")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetProductStats", time.Now(), &code)
+
+	if s.stats != nil {
+		if stats, ok := s.stats.Get(statsCacheKey); ok {
+			return stats, http.StatusOK, nil
+		}
+	}
+
+	total, code, err := s.products.CountProducts(ctx, entity.GetProductsRequest{})
+	if err != nil {
+		return nil, code, err
+	}
+
+	inStock := false
+	outOfStock, code, err := s.products.CountProducts(ctx, entity.GetProductsRequest{InStock: &inStock})
+	if err != nil {
+		return nil, code, err
+	}
+
+	stats := &ProductStats{
+		Total:      total,
+		OutOfStock: outOfStock,
+	}
+	if s.stats != nil {
+		s.stats.Set(statsCacheKey, stats)
+	}
+
+	return stats, code, nil
+}
+
+type PurgeProductsResponse struct {
+	Purged        int       `json:"purged"`
+	DeletedBefore time.Time `json:"deleted_before"`
+}
+
+func (s *service) PurgeProducts(ctx context.Context) (_ *PurgeProductsResponse, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:PurgeProducts")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "PurgeProducts", time.Now(), &code)
+
+	deletedBefore := time.Now().Add(-s.cfg.PurgeRetention)
+
+	purged, code, err := s.products.PurgeProducts(ctx, deletedBefore)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return &PurgeProductsResponse{
+		Purged:        purged,
+		DeletedBefore: deletedBefore,
+	}, code, nil
+}
+
+type AdjustStockRequest struct {
+	Delta       int    `json:"delta" validate:"required"`
+	Reason      string `json:"reason" validate:"required"`
+	WarehouseID string `json:"warehouse_id"`
+}
+
+func (s *service) AdjustStock(ctx context.Context, productID entity.ProductID, data AdjustStockRequest) (_ *Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:AdjustStock")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "AdjustStock", time.Now(), &code)
+
+	if err := utils.Validate(&data); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if data.WarehouseID != "" {
+		if _, code, err := s.warehouses.GetWarehouseByID(ctx, data.WarehouseID); err != nil {
+			return nil, code, err
+		}
+	}
+
+	var product *entity.Products
+	code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+		var code int
+		var err error
+		product, code, err = s.products.AdjustStock(ctx, productID, entity.AdjustStockRequest{
+			Delta:       data.Delta,
+			Reason:      data.Reason,
+			WarehouseID: data.WarehouseID,
+		})
+		if err != nil {
+			return code, err
+		}
+
+		return s.recordStockMovement(ctx, product, data.WarehouseID, data.Delta, data.Reason)
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	return productFromEntity(product), code, nil
+}
+
+type TransferStockRequest struct {
+	FromWarehouseID string `json:"from_warehouse_id"`
+	ToWarehouseID   string `json:"to_warehouse_id"`
+	Quantity        int    `json:"quantity" validate:"required,gt=0"`
+	Reason          string `json:"reason" validate:"required"`
+}
+
+func (s *service) TransferStock(ctx context.Context, productID entity.ProductID, data TransferStockRequest) (_ *Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:TransferStock")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "TransferStock", time.Now(), &code)
+
+	if err := utils.Validate(&data); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	if data.FromWarehouseID == data.ToWarehouseID {
+		return nil, http.StatusBadRequest, errors.ErrSameTransferLocation
+	}
+
+	for _, warehouseID := range []string{data.FromWarehouseID, data.ToWarehouseID} {
+		if warehouseID == "" {
+			continue
+		}
+		if _, code, err := s.warehouses.GetWarehouseByID(ctx, warehouseID); err != nil {
+			return nil, code, err
+		}
+	}
+
+	var product *entity.Products
+	code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+		var code int
+		var err error
+		product, code, err = s.products.TransferStock(ctx, productID, entity.TransferStockRequest{
+			FromWarehouseID: data.FromWarehouseID,
+			ToWarehouseID:   data.ToWarehouseID,
+			Quantity:        data.Quantity,
+		})
+		if err != nil {
+			return code, err
+		}
+
+		// Total stock doesn't change, both legs are kept for the audit
+		// trail. A leg without a warehouse moves unallocated stock, so
+		// that is its balance rather than the total.
+		if code, err := s.recordTransferLeg(ctx, product, data.FromWarehouseID, -data.Quantity, data.Reason); err != nil {
+			return code, err
+		}
+		return s.recordTransferLeg(ctx, product, data.ToWarehouseID, data.Quantity, data.Reason)
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	return productFromEntity(product), code, nil
+}
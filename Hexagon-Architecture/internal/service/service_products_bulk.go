@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"time"
+)
+
+// maxBulkOperations is how many operations one bulk request can have.
+const maxBulkOperations = 500
+
+type BulkProductsRequest struct {
+	Operations []BulkProductOperation `json:"operations"`
+}
+
+// BulkProductOperation is one create, update or delete. Product is a
+// CreateProductRequest for create and an UpdateProductRequest for update.
+type BulkProductOperation struct {
+	Op      string          `json:"op" validate:"required,oneof=create update delete" mod:"trim,lcase"`
+	ID      string          `json:"id" validate:"required_unless=Op create" mod:"trim"`
+	Version *int            `json:"version"`
+	Product json.RawMessage `json:"product"`
+}
+
+type BulkProductResult struct {
+	Index   int       `json:"index"`
+	ID      string    `json:"id,omitempty"`
+	Status  int       `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Product *Products `json:"product,omitempty"`
+}
+
+// BulkProducts runs every operation on its own, a failing one doesn't stop
+// the others. The result of each is at the same index.
+func (s *service) BulkProducts(ctx context.Context, req BulkProductsRequest) (_ []*BulkProductResult, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:BulkProducts")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "BulkProducts", time.Now(), &code)
+
+	if len(req.Operations) == 0 {
+		return nil, http.StatusBadRequest, errors.ErrRequiredField("operations")
+	}
+	if len(req.Operations) > maxBulkOperations {
+		return nil, http.StatusBadRequest, errors.ErrTooManyBulkOperations
+	}
+
+	results := make([]*BulkProductResult, len(req.Operations))
+	var operations []entity.BulkOperation
+	var operationIndex []int
+	seen := make(map[entity.ProductID]bool)
+	for i, op := range req.Operations {
+		operation, err := s.bulkOperation(ctx, op)
+		if err == nil && operation.ID != "" && seen[operation.ID] {
+			err = errors.ErrDuplicateBulkProduct
+		}
+		if err != nil {
+			results[i] = &BulkProductResult{
+				Index:  i,
+				ID:     op.ID,
+				Status: http.StatusBadRequest,
+				Error:  err.Error(),
+			}
+			continue
+		}
+
+		seen[operation.ID] = true
+		operations = append(operations, operation)
+		operationIndex = append(operationIndex, i)
+	}
+
+	if len(operations) > 0 {
+		written, code, err := s.writeBulk(ctx, operations, "")
+		if err != nil {
+			return nil, code, err
+		}
+
+		for j, result := range written {
+			i := operationIndex[j]
+			results[i] = &BulkProductResult{
+				Index:  i,
+				ID:     result.ID,
+				Status: result.Code,
+			}
+			if result.Err != nil {
+				results[i].Error = result.Err.Error()
+				continue
+			}
+			if result.Product != nil {
+				results[i].Product = productFromEntity(result.Product)
+			}
+		}
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// writeBulk to write operations and record the stock they changed in the
+// ledger, with reason or else the operation name. Each operation has a
+// transaction of its own, since a failing write aborts the transaction it
+// is in and one operation mustn't fail the others.
+func (s *service) writeBulk(ctx context.Context, operations []entity.BulkOperation, reason string) ([]entity.BulkResult, int, error) {
+	results := make([]entity.BulkResult, len(operations))
+	for j, operation := range operations {
+		movementReason := reason
+		if movementReason == "" {
+			movementReason = operation.Op
+		}
+
+		var result entity.BulkResult
+		code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+			written, code, err := s.products.BulkWriteProducts(ctx, []entity.BulkOperation{operation})
+			if err != nil {
+				return code, err
+			}
+
+			result = written[0]
+			if result.Err != nil {
+				return result.Code, result.Err
+			}
+			if result.Product == nil {
+				return result.Code, nil
+			}
+
+			return s.recordStockMovement(ctx, result.Product, "", result.StockDelta, movementReason)
+		})
+		if err != nil {
+			result = entity.BulkResult{ID: result.ID, Code: code, Err: err}
+		}
+
+		result.Index = j
+		results[j] = result
+	}
+
+	return results, http.StatusOK, nil
+}
+
+// bulkOperation to validate a bulk operation the same way as its single
+// product counterpart.
+func (s *service) bulkOperation(ctx context.Context, op BulkProductOperation) (entity.BulkOperation, error) {
+	if err := utils.Validate(&op); err != nil {
+		return entity.BulkOperation{}, err
+	}
+
+	operation := entity.BulkOperation{
+		Op: op.Op,
+	}
+	if op.Op != entity.BulkCreate {
+		id, err := entity.ParseProductID(op.ID)
+		if err != nil {
+			return entity.BulkOperation{}, err
+		}
+		operation.ID = id
+	}
+
+	switch op.Op {
+	case entity.BulkCreate:
+		if len(op.Product) == 0 {
+			return entity.BulkOperation{}, errors.ErrRequiredField("product")
+		}
+
+		var data CreateProductRequest
+		if err := utils.DecodeJSON(op.Product, &data); err != nil {
+			return entity.BulkOperation{}, err
+		}
+		if err := utils.Validate(&data); err != nil {
+			return entity.BulkOperation{}, err
+		}
+
+		operation.Product = data.toEntity()
+	case entity.BulkUpdate:
+		if len(op.Product) == 0 {
+			return entity.BulkOperation{}, errors.ErrRequiredField("product")
+		}
+
+		var data UpdateProductRequest
+		if err := utils.DecodeJSON(op.Product, &data); err != nil {
+			return entity.BulkOperation{}, err
+		}
+		if err := utils.Validate(&data); err != nil {
+			return entity.BulkOperation{}, err
+		}
+
+		operation.Update = data.toEntity(op.Version)
+	case entity.BulkDelete:
+		operation.DeletedBy = utils.GetActor(ctx)
+	}
+
+	return operation, nil
+}
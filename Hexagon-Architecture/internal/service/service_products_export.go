@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	productsRepo "hexagon-architecture/internal/domain/products/repository"
+	"hexagon-architecture/internal/infrastructure"
+	"net/http"
+	"time"
+)
+
+// ProductsIterator walks exported products one at a time. Close must be
+// called when done.
+type ProductsIterator struct {
+	products productsRepo.ProductsIterator
+}
+
+// ExportProducts returns every product matching the filter of req. Page
+// and limit are ignored.
+func (s *service) ExportProducts(ctx context.Context, req GetProductsRequest) (_ *ProductsIterator, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ExportProducts")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ExportProducts", time.Now(), &code)
+
+	query, err := req.toEntity()
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	products, code, err := s.products.ExportProducts(ctx, query)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return &ProductsIterator{products: products}, code, nil
+}
+
+// Next to move to the next product. It returns false when there is none
+// left or an error happens, see Err.
+func (it *ProductsIterator) Next(ctx context.Context) bool {
+	return it.products.Next(ctx)
+}
+
+// Product returns the current product.
+func (it *ProductsIterator) Product() *Products {
+	return productFromEntity(it.products.Product())
+}
+
+// Err returns the error that stopped Next, if any.
+func (it *ProductsIterator) Err() error {
+	return it.products.Err()
+}
+
+// Close to release the iterator.
+func (it *ProductsIterator) Close(ctx context.Context) error {
+	return it.products.Close(ctx)
+}
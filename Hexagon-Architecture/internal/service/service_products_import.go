@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportRows is how many products one import file can have.
+const maxImportRows = 10000
+
+// importTagSeparator separates tags in the tags column.
+const importTagSeparator = "|"
+
+// Import file columns. Columns left out of the file are left as they are
+// when a product is updated.
+var (
+	importColumns         = []string{"sku", "name_product", "stock", "description", "category", "tags", "price", "currency"}
+	requiredImportColumns = []string{"sku", "name_product", "stock"}
+)
+
+type ImportProductsRequest struct {
+	File   io.Reader
+	DryRun bool
+}
+
+// ImportProductsResponse is the import summary. On dry run, created and
+// updated are what the import would do.
+type ImportProductsResponse struct {
+	DryRun  bool              `json:"dry_run"`
+	Rows    int               `json:"rows"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Errors  []*ImportRowError `json:"errors"`
+}
+
+// ImportRowError is why a row was not imported. Row is the line in the
+// file, the header being line 1.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	SKU   string `json:"sku"`
+	Error string `json:"error"`
+}
+
+type importRow struct {
+	line   int
+	values map[string]string
+	data   CreateProductRequest
+}
+
+// ImportProducts creates products from a CSV file, or updates them when
+// one with the same sku exists. Products without sku are matched by name.
+// Rows are validated like CreateProductRequest and a bad row doesn't stop
+// the others.
+func (s *service) ImportProducts(ctx context.Context, req ImportProductsRequest) (_ *ImportProductsResponse, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ImportProducts")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ImportProducts", time.Now(), &code)
+
+	columns, rows, err := readImportFile(req.File)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	response := &ImportProductsResponse{
+		DryRun: req.DryRun,
+		Rows:   len(rows),
+		Errors: []*ImportRowError{},
+	}
+	fail := func(row *importRow, err error) {
+		response.Failed++
+		response.Errors = append(response.Errors, &ImportRowError{
+			Row:   row.line,
+			SKU:   strings.TrimSpace(row.values["sku"]),
+			Error: err.Error(),
+		})
+	}
+
+	var valid []*importRow
+	var skus, names []string
+	seenSKU := make(map[string]bool)
+	for _, row := range rows {
+		err := row.parse()
+		if err == nil {
+			err = utils.Validate(&row.data)
+		}
+		if err == nil && seenSKU[row.data.SKU] {
+			err = errors.ErrDuplicateImportProduct
+		}
+		if err != nil {
+			fail(row, err)
+			continue
+		}
+
+		seenSKU[row.data.SKU] = true
+		valid = append(valid, row)
+		skus = append(skus, row.data.SKU)
+		names = append(names, row.data.Name)
+	}
+
+	var existing []*entity.Products
+	if len(valid) > 0 {
+		var code int
+		if existing, code, err = s.products.GetProductsBySKUOrName(ctx, skus, names); err != nil {
+			return nil, code, err
+		}
+	}
+
+	bySKU := make(map[string]*entity.Products)
+	byName := make(map[string]*entity.Products)
+	for _, product := range existing {
+		if product.SKU != "" {
+			bySKU[product.SKU] = product
+		} else {
+			byName[strings.ToLower(product.Name)] = product
+		}
+	}
+
+	var operations []entity.BulkOperation
+	var operationRows []*importRow
+	seenID := make(map[string]bool)
+	for _, row := range valid {
+		product := bySKU[row.data.SKU]
+		if product == nil {
+			product = byName[strings.ToLower(row.data.Name)]
+		}
+
+		if product == nil {
+			operations = append(operations, entity.BulkOperation{
+				Op:      entity.BulkCreate,
+				Product: row.data.toEntity(),
+			})
+			operationRows = append(operationRows, row)
+			continue
+		}
+
+		if seenID[product.ID] {
+			fail(row, errors.ErrDuplicateImportProduct)
+			continue
+		}
+		// A write checks stock against the product as stored, only a dry
+		// run has nothing better than the product as read.
+		if req.DryRun && (*row.data.Stock < product.Reserved || *row.data.Stock < product.Allocated()) {
+			fail(row, errors.ErrInsufficientStock)
+			continue
+		}
+
+		seenID[product.ID] = true
+		operations = append(operations, entity.BulkOperation{
+			Op:     entity.BulkUpdate,
+			ID:     entity.ProductID(product.ID),
+			Update: row.update(columns).toEntity(&product.Version),
+		})
+		operationRows = append(operationRows, row)
+	}
+
+	if req.DryRun {
+		for _, op := range operations {
+			response.count(op.Op)
+		}
+		response.sortErrors()
+		return response, http.StatusOK, nil
+	}
+
+	for start := 0; start < len(operations); start += maxBulkOperations {
+		end := min(start+maxBulkOperations, len(operations))
+
+		results, code, err := s.writeBulk(ctx, operations[start:end], "import")
+		if err != nil {
+			return nil, code, err
+		}
+
+		for j, result := range results {
+			if result.Err != nil {
+				fail(operationRows[start+j], result.Err)
+				continue
+			}
+			response.count(operations[start+j].Op)
+		}
+	}
+
+	response.sortErrors()
+
+	return response, http.StatusOK, nil
+}
+
+func (r *ImportProductsResponse) count(op string) {
+	if op == entity.BulkCreate {
+		r.Created++
+	} else {
+		r.Updated++
+	}
+}
+
+func (r *ImportProductsResponse) sortErrors() {
+	sort.SliceStable(r.Errors, func(i, j int) bool {
+		return r.Errors[i].Row < r.Errors[j].Row
+	})
+}
+
+// readImportFile returns lowercased header columns and rows of a CSV file.
+func readImportFile(file io.Reader) ([]string, []*importRow, error) {
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	columns, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.ErrEmptyImportFile
+	}
+	if err != nil {
+		return nil, nil, importFileError(err)
+	}
+
+	for i, column := range columns {
+		// Spreadsheet apps like to start the file with a byte order mark.
+		columns[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !slices.Contains(importColumns, columns[i]) {
+			return nil, nil, errors.ErrUnknownImportColumn(columns[i])
+		}
+		if slices.Index(columns, columns[i]) != i {
+			return nil, nil, errors.ErrDuplicateImportColumn(columns[i])
+		}
+	}
+	for _, column := range requiredImportColumns {
+		if !slices.Contains(columns, column) {
+			return nil, nil, errors.ErrMissingImportColumn(column)
+		}
+	}
+
+	var rows []*importRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, importFileError(err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		if len(rows) == maxImportRows {
+			return nil, nil, errors.ErrTooManyImportRows
+		}
+
+		row := &importRow{line: line, values: make(map[string]string, len(columns))}
+		for i, column := range columns {
+			row.values[column] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return columns, rows, nil
+}
+
+func importFileError(err error) error {
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		return errors.ErrInvalidImportLine(parseErr.StartLine)
+	}
+	return errors.ErrInvalidRequestFormat
+}
+
+// parse to fill row data from its values.
+func (r *importRow) parse() error {
+	r.data = CreateProductRequest{
+		SKU:         r.values["sku"],
+		Name:        strings.TrimSpace(r.values["name_product"]),
+		Description: r.values["description"],
+		Category:    r.values["category"],
+		Currency:    r.values["currency"],
+	}
+
+	stock, err := strconv.Atoi(strings.TrimSpace(r.values["stock"]))
+	if err != nil {
+		return errors.ErrInvalidImportValue("stock")
+	}
+	r.data.Stock = &stock
+
+	if price := strings.TrimSpace(r.values["price"]); price != "" {
+		if r.data.Price, err = strconv.ParseInt(price, 10, 64); err != nil {
+			return errors.ErrInvalidImportValue("price")
+		}
+	}
+
+	for _, tag := range strings.Split(r.values["tags"], importTagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			r.data.Tags = append(r.data.Tags, tag)
+		}
+	}
+
+	return nil
+}
+
+// update is the update setting a product to the row, leaving fields of
+// columns not in the file alone.
+func (r *importRow) update(columns []string) UpdateProductRequest {
+	update := UpdateProductRequest{
+		SKU:   &r.data.SKU,
+		Name:  &r.data.Name,
+		Stock: r.data.Stock,
+	}
+
+	for _, column := range columns {
+		switch column {
+		case "description":
+			update.Description = &r.data.Description
+		case "category":
+			update.Category = &r.data.Category
+		case "tags":
+			update.Tags = r.data.Tags
+			if update.Tags == nil {
+				update.Tags = []string{}
+			}
+		case "price":
+			update.Price = &r.data.Price
+		case "currency":
+			update.Currency = &r.data.Currency
+		}
+	}
+
+	return update
+}
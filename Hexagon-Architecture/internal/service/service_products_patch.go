@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"hexagon-architecture/pkg/jsonpatch"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// ReplaceProductRequest is every writable field of a product, validated
+// like CreateProductRequest. Omitted fields are cleared, except stock
+// which must be given so it is never zeroed by accident.
+type ReplaceProductRequest struct {
+	SKU         string   `json:"sku" validate:"required,max=64" mod:"trim"`
+	Name        string   `json:"name_product" validate:"required"`
+	Description string   `json:"description" validate:"max=2000" mod:"trim"`
+	Category    string   `json:"category" validate:"max=64" mod:"trim,lcase"`
+	Tags        []string `json:"tags" validate:"max=20,dive,required,max=32" mod:"dive,trim,lcase"`
+	Price       int64    `json:"price" validate:"gte=0"`
+	Currency    string   `json:"currency" validate:"required_with=Price,omitempty,iso4217" mod:"trim,ucase"`
+	Stock       *int     `json:"stock" validate:"required,gte=0"`
+
+	// Versions are the product versions the client expects to replace,
+	// any of them will do. Empty means replace regardless of the current
+	// version.
+	Versions []int `json:"-"`
+}
+
+// ReplaceProduct to overwrite every writable field of a product.
+func (s *service) ReplaceProduct(ctx context.Context, productID entity.ProductID, data ReplaceProductRequest) (_ *Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ReplaceProduct")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ReplaceProduct", time.Now(), &code)
+
+	if err := utils.Validate(&data); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	return s.updateProduct(ctx, productID, data.toUpdate())
+}
+
+func (r ReplaceProductRequest) toUpdate() UpdateProductRequest {
+	tags := r.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return UpdateProductRequest{
+		SKU:         &r.SKU,
+		Name:        &r.Name,
+		Description: &r.Description,
+		Category:    &r.Category,
+		Tags:        tags,
+		Price:       &r.Price,
+		Currency:    &r.Currency,
+		Stock:       r.Stock,
+		Versions:    r.Versions,
+	}
+}
+
+func replaceRequestFromEntity(product *entity.Products) ReplaceProductRequest {
+	tags := product.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return ReplaceProductRequest{
+		SKU:         product.SKU,
+		Name:        product.Name,
+		Description: product.Description,
+		Category:    product.Category,
+		Tags:        tags,
+		Price:       product.Price,
+		Currency:    product.Currency,
+		Stock:       &product.Stock,
+	}
+}
+
+// Patch type list.
+const (
+	// PatchMerge is JSON Merge Patch (RFC 7396).
+	PatchMerge = "merge"
+	// PatchJSON is JSON Patch (RFC 6902).
+	PatchJSON = "json"
+)
+
+type PatchProductRequest struct {
+	Type  string
+	Patch []byte
+
+	// Versions are the product versions the client expects to patch, any
+	// of them will do. Empty means patch whatever the current version is.
+	Versions []int
+}
+
+// PatchProduct to apply a patch to the writable fields of a product, as
+// they are in ReplaceProductRequest, then replace the product with the
+// result.
+func (s *service) PatchProduct(ctx context.Context, productID entity.ProductID, req PatchProductRequest) (_ *Products, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:PatchProduct")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "PatchProduct", time.Now(), &code)
+
+	apply := jsonpatch.Merge
+	switch req.Type {
+	case PatchMerge:
+	case PatchJSON:
+		apply = jsonpatch.Apply
+	default:
+		return nil, http.StatusUnsupportedMediaType, errors.ErrUnsupportedMediaType
+	}
+
+	// The patch is applied to the product as read, so the replace is
+	// pinned to that version. A concurrent change just means checking
+	// and patching again.
+	for attempt := 1; ; attempt++ {
+		current, code, err := s.products.GetProductByID(ctx, productID)
+		if err != nil {
+			return nil, code, err
+		}
+		if len(req.Versions) > 0 && !slices.Contains(req.Versions, current.Version) {
+			return nil, http.StatusPreconditionFailed, errors.ErrProductVersionMismatch
+		}
+
+		doc, err := json.Marshal(replaceRequestFromEntity(current))
+		if err != nil {
+			return nil, http.StatusInternalServerError, errors.ErrInternalServer
+		}
+
+		patched, err := apply(doc, req.Patch)
+		if err != nil {
+			if err == jsonpatch.ErrTestFailed {
+				return nil, http.StatusConflict, errors.ErrPatchTestFailed
+			}
+			return nil, http.StatusBadRequest, errors.ErrInvalidPatch
+		}
+
+		data, err := decodePatchedProduct(patched)
+		if err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		data.Versions = []int{current.Version}
+
+		var product *Products
+		if current.SKU == "" && data.SKU == "" {
+			product, code, err = s.replaceProductWithoutSKU(ctx, productID, data)
+		} else {
+			product, code, err = s.ReplaceProduct(ctx, productID, data)
+		}
+		if code == http.StatusPreconditionFailed && attempt < maxUpdateAttempts {
+			continue
+		}
+		return product, code, err
+	}
+}
+
+// replaceProductWithoutSKU to replace a product stored before sku was
+// required, which a patch leaving sku out keeps without one. The other
+// fields are validated like ReplaceProduct does.
+func (s *service) replaceProductWithoutSKU(ctx context.Context, id entity.ProductID, data ReplaceProductRequest) (*Products, int, error) {
+	// Sku is left out of the update, so any valid one does for the check.
+	data.SKU = "-"
+	if err := utils.Validate(&data); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	update := data.toUpdate()
+	update.SKU = nil
+
+	return s.updateProduct(ctx, id, update)
+}
+
+// decodePatchedProduct rejects patches adding fields a product doesn't
+// have or giving a field the wrong type.
+func decodePatchedProduct(patched []byte) (ReplaceProductRequest, error) {
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+
+	var data ReplaceProductRequest
+	if err := decoder.Decode(&data); err != nil {
+		return ReplaceProductRequest{}, errors.ErrInvalidPatch
+	}
+	return data, nil
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SearchProductsRequest struct {
+	Query    string `validate:"required,max=256" mod:"trim"`
+	Category string `mod:"trim,lcase"`
+	Limit    int    `validate:"gte=1,lte=100"`
+}
+
+type SearchProductsResponse struct {
+	Hits   []*SearchHit `json:"hits"`
+	Facets SearchFacets `json:"facets"`
+}
+
+type SearchHit struct {
+	*Products
+	Score float64 `json:"score"`
+}
+
+type SearchFacets struct {
+	Category []FacetCount `json:"category"`
+	Stock    []FacetCount `json:"stock"`
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+func (s *service) SearchProducts(ctx context.Context, req SearchProductsRequest) (_ *SearchProductsResponse, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:SearchProducts")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "SearchProducts", time.Now(), &code)
+
+	if err := utils.Validate(&req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	result, code, err := s.products.Search(ctx, entity.SearchProductsRequest{
+		Query:    req.Query,
+		Category: req.Category,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	hits := make([]*SearchHit, len(result.Hits))
+	for i, hit := range result.Hits {
+		hits[i] = &SearchHit{
+			Products: productFromEntity(&hit.Product),
+			Score:    hit.Score,
+		}
+	}
+
+	return &SearchProductsResponse{
+		Hits: hits,
+		Facets: SearchFacets{
+			Category: facetCountsFromEntity(result.Facets.Category),
+			Stock:    facetCountsFromEntity(result.Facets.Stock),
+		},
+	}, code, nil
+}
+
+func facetCountsFromEntity(f []entity.FacetCount) []FacetCount {
+	counts := make([]FacetCount, len(f))
+	for i, count := range f {
+		counts[i] = FacetCount{
+			Value: count.Value,
+			Count: count.Count,
+		}
+	}
+	return counts
+}
+
+type SuggestProductsRequest struct {
+	Query string `validate:"required,max=64" mod:"trim"`
+	Limit int    `validate:"gte=1,lte=20"`
+}
+
+type Suggestion struct {
+	ID   string `json:"id"`
+	Name string `json:"name_product"`
+}
+
+func (s *service) SuggestProducts(ctx context.Context, req SuggestProductsRequest) (_ []*Suggestion, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:SuggestProducts")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "SuggestProducts", time.Now(), &code)
+
+	if err := utils.Validate(&req); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	key := strconv.Itoa(req.Limit) + ":" + strings.ToLower(req.Query)
+	if s.suggestions != nil {
+		if suggestions, ok := s.suggestions.Get(key); ok {
+			return suggestions, http.StatusOK, nil
+		}
+	}
+
+	result, code, err := s.products.SuggestProducts(ctx, req.Query, req.Limit)
+	if err != nil {
+		return nil, code, err
+	}
+
+	suggestions := make([]*Suggestion, len(result))
+	for i, suggestion := range result {
+		suggestions[i] = &Suggestion{
+			ID:   suggestion.ID,
+			Name: suggestion.Name,
+		}
+	}
+
+	if s.suggestions != nil {
+		s.suggestions.Set(key, suggestions)
+	}
+
+	return suggestions, code, nil
+}
@@ -0,0 +1,722 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"hexagon-architecture/internal/domain/products/entity"
+	productsRepo "hexagon-architecture/internal/domain/products/repository"
+	productsMemory "hexagon-architecture/internal/domain/products/repository/memory"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateProduct(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	tests := map[string]struct {
+		input        service.CreateProductRequest
+		expectedCode int
+		expectedErr  error
+	}{
+		"ok": {
+			input: service.CreateProductRequest{
+				SKU:      " APL-1 ",
+				Name:     "Apple",
+				Category: "Fruit",
+				Tags:     []string{"Red", " fresh"},
+				Price:    1250,
+				Currency: "usd",
+				Stock:    ptr(10),
+			},
+			expectedCode: http.StatusOK,
+		},
+		"duplicate-sku": {
+			input:        service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(1)},
+			expectedCode: http.StatusConflict,
+			expectedErr:  errors.ErrDuplicateSKU,
+		},
+		"missing-sku": {
+			input:        service.CreateProductRequest{Name: "Apple", Stock: ptr(1)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrRequiredField("sku"),
+		},
+		"invalid-currency": {
+			input:        service.CreateProductRequest{SKU: "BAN-1", Name: "Banana", Price: 100, Currency: "XYZ", Stock: ptr(1)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrISO4217Field("currency"),
+		},
+		"price-without-currency": {
+			input:        service.CreateProductRequest{SKU: "BAN-1", Name: "Banana", Price: 100, Stock: ptr(1)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrRequiredWithField("currency", "price"),
+		},
+		"negative-price": {
+			input:        service.CreateProductRequest{SKU: "BAN-1", Name: "Banana", Price: -1, Currency: "USD", Stock: ptr(1)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrGTEField("price", "0"),
+		},
+		"missing-stock": {
+			input:        service.CreateProductRequest{SKU: "BAN-1", Name: "Banana"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrRequiredField("stock"),
+		},
+		"negative-stock": {
+			input:        service.CreateProductRequest{SKU: "BAN-1", Name: "Banana", Stock: ptr(-1)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrGTEField("stock", "0"),
+		},
+		"out-of-stock": {
+			input:        service.CreateProductRequest{SKU: "BAN-1", Name: "Banana", Stock: ptr(0)},
+			expectedCode: http.StatusOK,
+		},
+	}
+
+	for _, name := range []string{"ok", "duplicate-sku", "missing-sku", "invalid-currency", "price-without-currency", "negative-price", "missing-stock", "negative-stock", "out-of-stock"} {
+		test := tests[name]
+		_, code, err := s.CreateProduct(ctx, test.input)
+		assert.Equal(t, test.expectedCode, code, name)
+		assert.Equal(t, test.expectedErr, err, name)
+	}
+
+	products, pagination, code, err := s.GetProducts(ctx, service.GetProductsRequest{Page: 1, Limit: 5, Category: "fruit", Tag: "FRESH", Currency: "usd"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, pagination.Total)
+	assert.Equal(t, "APL-1", products[0].SKU)
+	assert.Equal(t, "fruit", products[0].Category)
+	assert.Equal(t, []string{"red", "fresh"}, products[0].Tags)
+	assert.Equal(t, int64(1250), products[0].Price)
+	assert.Equal(t, "USD", products[0].Currency)
+}
+
+func TestGetProductsNameMatch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	for i, name := range []string{"Apple", "Pineapple", "Apple.Pie", "apple"} {
+		_, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: string(rune('A' + i)), Name: name, Stock: ptr(1)})
+		assert.NoError(t, err)
+	}
+
+	tests := map[string]struct {
+		input         service.GetProductsRequest
+		expectedNames []string
+		expectedCode  int
+		expectedErr   error
+	}{
+		"contains": {
+			input:         service.GetProductsRequest{Name: "apple"},
+			expectedNames: []string{"Apple", "Pineapple", "Apple.Pie", "apple"},
+			expectedCode:  http.StatusOK,
+		},
+		"contains-literal": {
+			input:         service.GetProductsRequest{Name: "e.p"},
+			expectedNames: []string{"Apple.Pie"},
+			expectedCode:  http.StatusOK,
+		},
+		"prefix": {
+			input:         service.GetProductsRequest{Name: "app", Match: "prefix"},
+			expectedNames: []string{"Apple", "Apple.Pie", "apple"},
+			expectedCode:  http.StatusOK,
+		},
+		"exact": {
+			input:         service.GetProductsRequest{Name: "APPLE", Match: "exact"},
+			expectedNames: []string{"Apple", "apple"},
+			expectedCode:  http.StatusOK,
+		},
+		"regex": {
+			input:         service.GetProductsRequest{Name: "^(pine)?apple$", Match: "regex"},
+			expectedNames: []string{"Apple", "Pineapple", "apple"},
+			expectedCode:  http.StatusOK,
+		},
+		"regex-invalid": {
+			input:        service.GetProductsRequest{Name: "(apple", Match: "regex"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidNameRegex,
+		},
+		"regex-nested-repeat": {
+			input:        service.GetProductsRequest{Name: "(a+)+$", Match: "regex"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrNameRegexTooComplex,
+		},
+		"regex-alternation-in-repeat": {
+			input:        service.GetProductsRequest{Name: "(a|aa)*$", Match: "regex"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrNameRegexTooComplex,
+		},
+		"regex-optional-alternative-in-repeat": {
+			input:        service.GetProductsRequest{Name: "(a|a?)+$", Match: "regex"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrNameRegexTooComplex,
+		},
+		"regex-class-in-repeat": {
+			input:         service.GetProductsRequest{Name: "^(p|a)+le$", Match: "regex"},
+			expectedNames: []string{"Apple", "apple"},
+			expectedCode:  http.StatusOK,
+		},
+		"regex-too-long": {
+			input:        service.GetProductsRequest{Name: strings.Repeat("a", 65), Match: "regex"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrNameRegexTooComplex,
+		},
+		"sort": {
+			input:         service.GetProductsRequest{Name: "apple", Sort: "-name, stock"},
+			expectedNames: []string{"apple", "Pineapple", "Apple.Pie", "Apple"},
+			expectedCode:  http.StatusOK,
+		},
+		"sort-unknown-field": {
+			input:        service.GetProductsRequest{Sort: "price"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidSortField("price"),
+		},
+		"sort-repeated-field": {
+			input:        service.GetProductsRequest{Sort: "name,-name"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidSortField("-name"),
+		},
+		"unknown-mode": {
+			input:        service.GetProductsRequest{Name: "apple", Match: "fuzzy"},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrOneOfField("match", "contains prefix exact regex"),
+		},
+	}
+
+	for name, test := range tests {
+		test.input.Page, test.input.Limit = 1, 10
+		products, _, code, err := s.GetProducts(ctx, test.input)
+		assert.Equal(t, test.expectedCode, code, name)
+		assert.Equal(t, test.expectedErr, err, name)
+
+		var names []string
+		for _, p := range products {
+			names = append(names, p.Name)
+		}
+		assert.Equal(t, test.expectedNames, names, name)
+	}
+}
+
+func TestGetProductsByCursorDefaultLimit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	for i := 0; i < 6; i++ {
+		_, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: string(rune('A' + i)), Name: "Apple", Stock: ptr(i + 1)})
+		assert.NoError(t, err)
+	}
+
+	products, cursor, code, err := s.GetProductsByCursor(ctx, service.GetProductsRequest{}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, products, 5)
+	assert.Equal(t, 5, cursor.Limit)
+	assert.NotEmpty(t, cursor.NextCursor)
+
+	products, cursor, _, err = s.GetProductsByCursor(ctx, service.GetProductsRequest{}, cursor.NextCursor)
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	assert.Empty(t, cursor.NextCursor)
+}
+
+func TestSearchProducts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	for _, product := range []service.CreateProductRequest{
+		{SKU: "A", Name: "Green Apple", Category: "fruit", Tags: []string{"apple"}, Stock: ptr(20)},
+		{SKU: "B", Name: "Apple Juice", Category: "drink", Description: "Made from apple", Stock: ptr(5)},
+		{SKU: "C", Name: "Apple Pie", Category: "bakery", Stock: ptr(3)},
+		{SKU: "D", Name: "Banana", Category: "fruit", Description: "Not an apple", Stock: ptr(2)},
+		{SKU: "E", Name: "Cherry", Category: "fruit", Stock: ptr(9)},
+	} {
+		_, _, err := s.CreateProduct(ctx, product)
+		assert.NoError(t, err)
+	}
+
+	result, code, err := s.SearchProducts(ctx, service.SearchProductsRequest{Query: "APPLE", Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var skus []string
+	for _, hit := range result.Hits {
+		skus = append(skus, hit.SKU)
+	}
+	assert.Equal(t, []string{"A", "B"}, skus)
+	assert.Equal(t, 15.0, result.Hits[0].Score)
+	assert.Equal(t, []service.FacetCount{
+		{Value: "fruit", Count: 2},
+		{Value: "bakery", Count: 1},
+		{Value: "drink", Count: 1},
+	}, result.Facets.Category)
+	assert.Equal(t, []service.FacetCount{
+		{Value: "low", Count: 3},
+		{Value: "in_stock", Count: 1},
+	}, result.Facets.Stock)
+
+	result, _, _ = s.SearchProducts(ctx, service.SearchProductsRequest{Query: "apple", Category: "Fruit", Limit: 10})
+	assert.Len(t, result.Hits, 2)
+
+	_, code, err = s.SearchProducts(ctx, service.SearchProductsRequest{Query: " ", Limit: 10})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrRequiredField("query"), err)
+}
+
+func TestSuggestProducts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, withConfig(service.Config{SuggestCacheTTL: time.Minute}))
+
+	for i, name := range []string{"Apricot", "apple", "Banana", "Apple Pie"} {
+		_, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: string(rune('A' + i)), Name: name, Stock: ptr(1)})
+		assert.NoError(t, err)
+	}
+
+	suggestions, code, err := s.SuggestProducts(ctx, service.SuggestProductsRequest{Query: "AP", Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var names []string
+	for _, suggestion := range suggestions {
+		names = append(names, suggestion.Name)
+	}
+	assert.Equal(t, []string{"apple", "Apple Pie"}, names)
+
+	_, _, err = s.CreateProduct(ctx, service.CreateProductRequest{SKU: "E", Name: "Apex", Stock: ptr(1)})
+	assert.NoError(t, err)
+
+	cached, _, _ := s.SuggestProducts(ctx, service.SuggestProductsRequest{Query: "ap", Limit: 2})
+	assert.Equal(t, suggestions, cached)
+
+	_, code, err = s.SuggestProducts(ctx, service.SuggestProductsRequest{Query: "ap", Limit: 50})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrLTEField("limit", "20"), err)
+}
+
+func TestExportProducts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	for i, name := range []string{"Cherry", "Apple", "Banana"} {
+		_, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: string(rune('A' + i)), Name: name, Category: "fruit", Stock: ptr(i + 1)})
+		assert.NoError(t, err)
+	}
+	_, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "D", Name: "Bread", Category: "bakery", Stock: ptr(5)})
+	assert.NoError(t, err)
+
+	products, code, err := s.ExportProducts(ctx, service.GetProductsRequest{Category: "Fruit", Sort: "-name"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var names []string
+	for products.Next(ctx) {
+		names = append(names, products.Product().Name)
+	}
+	assert.NoError(t, products.Err())
+	assert.NoError(t, products.Close(ctx))
+	assert.Equal(t, []string{"Cherry", "Banana", "Apple"}, names)
+
+	_, code, err = s.ExportProducts(ctx, service.GetProductsRequest{Sort: "price"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrInvalidSortField("price"), err)
+}
+
+func TestReplaceProduct(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	product, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Description: "Red", Tags: []string{"fruit"}, Stock: ptr(10)})
+	assert.NoError(t, err)
+
+	stock := 0
+	replaced, code, err := s.ReplaceProduct(ctx, productID(product.ID), service.ReplaceProductRequest{SKU: "APL-2", Name: "Green Apple", Stock: &stock})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "APL-2", replaced.SKU)
+	assert.Equal(t, "Green Apple", replaced.Name)
+	assert.Empty(t, replaced.Description, "omitted field is cleared")
+	assert.Empty(t, replaced.Tags)
+	assert.Equal(t, 0, replaced.Stock)
+
+	_, code, err = s.ReplaceProduct(ctx, productID(product.ID), service.ReplaceProductRequest{SKU: "APL-2", Name: "Green Apple"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrRequiredField("stock"), err)
+
+	_, code, err = s.ReplaceProduct(ctx, productID(product.ID), service.ReplaceProductRequest{SKU: "APL-2", Stock: &stock})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrRequiredField("name"), err)
+
+	staleVersion := product.Version
+	_, code, err = s.ReplaceProduct(ctx, productID(product.ID), service.ReplaceProductRequest{SKU: "APL-2", Name: "Green Apple", Stock: &stock, Versions: []int{staleVersion}})
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, errors.ErrProductVersionMismatch, err)
+
+	_, code, err = s.ReplaceProduct(ctx, productID(product.ID), service.ReplaceProductRequest{SKU: "APL-2", Name: "Green Apple", Stock: &stock, Versions: []int{staleVersion, replaced.Version}})
+	assert.NoError(t, err, "any listed version may match")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestPatchProduct(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	product, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Description: "Red", Tags: []string{"fruit"}, Stock: ptr(10)})
+	assert.NoError(t, err)
+
+	staleVersion := product.Version - 1
+
+	tests := []struct {
+		name         string
+		input        service.PatchProductRequest
+		expectedCode int
+		expectedErr  error
+		check        func(t *testing.T, product *service.Products)
+	}{
+		{
+			name:         "merge",
+			input:        service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"description":null,"category":"Fresh","stock":12}`)},
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, product *service.Products) {
+				assert.Equal(t, "Apple", product.Name)
+				assert.Empty(t, product.Description)
+				assert.Equal(t, "fresh", product.Category)
+				assert.Equal(t, 12, product.Stock)
+			},
+		},
+		{
+			name:         "json",
+			input:        service.PatchProductRequest{Type: service.PatchJSON, Patch: []byte(`[{"op":"test","path":"/name_product","value":"Apple"},{"op":"add","path":"/tags/-","value":"Red"}]`)},
+			expectedCode: http.StatusOK,
+			check: func(t *testing.T, product *service.Products) {
+				assert.Equal(t, []string{"fruit", "red"}, product.Tags)
+			},
+		},
+		{
+			name:         "json-test-failed",
+			input:        service.PatchProductRequest{Type: service.PatchJSON, Patch: []byte(`[{"op":"test","path":"/name_product","value":"Pear"}]`)},
+			expectedCode: http.StatusConflict,
+			expectedErr:  errors.ErrPatchTestFailed,
+		},
+		{
+			name:         "clear-name",
+			input:        service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"name_product":""}`)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrRequiredField("name"),
+		},
+		{
+			name:         "unknown-field",
+			input:        service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"version":9}`)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidPatch,
+		},
+		{
+			name:         "wrong-type",
+			input:        service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"stock":"many"}`)},
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  errors.ErrInvalidPatch,
+		},
+		{
+			name:         "stale-version",
+			input:        service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"stock":1}`), Versions: []int{staleVersion}},
+			expectedCode: http.StatusPreconditionFailed,
+			expectedErr:  errors.ErrProductVersionMismatch,
+		},
+		{
+			name:         "unsupported-type",
+			input:        service.PatchProductRequest{Type: "xml", Patch: []byte(`<stock/>`)},
+			expectedCode: http.StatusUnsupportedMediaType,
+			expectedErr:  errors.ErrUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patched, code, err := s.PatchProduct(ctx, productID(product.ID), test.input)
+			assert.Equal(t, test.expectedCode, code)
+			assert.Equal(t, test.expectedErr, err)
+			if test.check != nil {
+				test.check(t, patched)
+			}
+		})
+	}
+
+	movements, _, _, err := s.GetStockMovements(ctx, productID(product.ID), service.GetStockMovementsRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 2, "patched stock goes to the ledger")
+}
+
+func TestPatchProductWithoutSKU(t *testing.T) {
+	ctx := context.Background()
+	products := productsMemory.New()
+	s := newTestService(t, withProducts(products))
+
+	// As stored before sku was required.
+	legacy, _, err := products.CreateProduct(ctx, entity.Products{Name: "Banana", Stock: 2})
+	assert.NoError(t, err)
+
+	patched, code, err := s.PatchProduct(ctx, productID(legacy.ID), service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"stock":5}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, patched.Stock)
+	assert.Empty(t, patched.SKU)
+
+	_, code, err = s.PatchProduct(ctx, productID(legacy.ID), service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"name_product":""}`)})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrRequiredField("name"), err, "other fields are still validated")
+
+	patched, code, err = s.PatchProduct(ctx, productID(legacy.ID), service.PatchProductRequest{Type: service.PatchMerge, Patch: []byte(`{"sku":"BAN-1"}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "BAN-1", patched.SKU)
+}
+
+func TestGetProductStats(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, withConfig(service.Config{StatsCacheTTL: time.Minute}))
+
+	apple, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(2)})
+	assert.NoError(t, err)
+	_, _, err = s.CreateProduct(ctx, service.CreateProductRequest{SKU: "BNN-1", Name: "Banana", Stock: ptr(5)})
+	assert.NoError(t, err)
+	cherry, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "CHR-1", Name: "Cherry", Stock: ptr(1)})
+	assert.NoError(t, err)
+
+	_, _, err = s.AdjustStock(ctx, productID(apple.ID), service.AdjustStockRequest{Delta: -2, Reason: "sale"})
+	assert.NoError(t, err)
+	_, err = s.DeleteProduct(ctx, productID(cherry.ID))
+	assert.NoError(t, err)
+
+	stats, code, err := s.GetProductStats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, &service.ProductStats{Total: 2, OutOfStock: 1}, stats)
+
+	_, _, err = s.CreateProduct(ctx, service.CreateProductRequest{SKU: "DRN-1", Name: "Durian", Stock: ptr(3)})
+	assert.NoError(t, err)
+
+	cached, _, err := s.GetProductStats(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, stats, cached, "served from the cache")
+}
+
+func TestBulkProducts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	existing, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "A", Name: "Apple", Stock: ptr(5)})
+	deleted, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "B", Name: "Banana", Stock: ptr(5)})
+
+	results, code, err := s.BulkProducts(ctx, service.BulkProductsRequest{
+		Operations: []service.BulkProductOperation{
+			{Op: "create", Product: []byte(`{"sku":"C","name_product":"Cherry","stock":3}`)},
+			{Op: "create", Product: []byte(`{"sku":"A","name_product":"Apricot","stock":3}`)},
+			{Op: "create", Product: []byte(`{"name_product":"Durian","stock":3}`)},
+			{Op: "update", ID: existing.ID, Product: []byte(`{"stock":8}`)},
+			{Op: "delete", ID: deleted.ID},
+			{Op: "delete", ID: deleted.ID},
+			{Op: "update", ID: "invalid", Product: []byte(`{"stock":1}`)},
+			{Op: "upsert"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	type result struct {
+		Status int
+		Error  string
+	}
+	var got []result
+	for i, r := range results {
+		assert.Equal(t, i, r.Index)
+		got = append(got, result{r.Status, r.Error})
+	}
+	assert.Equal(t, []result{
+		{Status: http.StatusOK},
+		{Status: http.StatusConflict, Error: errors.ErrDuplicateSKU.Error()},
+		{Status: http.StatusBadRequest, Error: errors.ErrRequiredField("sku").Error()},
+		{Status: http.StatusOK},
+		{Status: http.StatusOK},
+		{Status: http.StatusBadRequest, Error: errors.ErrDuplicateBulkProduct.Error()},
+		{Status: http.StatusBadRequest, Error: errors.ErrInvalidProductID.Error()},
+		{Status: http.StatusBadRequest, Error: errors.ErrOneOfField("op", "create update delete").Error()},
+	}, got)
+
+	assert.Equal(t, 8, results[3].Product.Stock)
+
+	movements, _, _, _ := s.GetStockMovements(ctx, productID(existing.ID), service.GetStockMovementsRequest{Page: 1, Limit: 10})
+	assert.Equal(t, 3, movements[0].Delta)
+	assert.Equal(t, "update", movements[0].Reason)
+
+	_, code, _ = s.GetProduct(ctx, productID(deleted.ID))
+	assert.Equal(t, http.StatusNotFound, code)
+
+	_, code, err = s.BulkProducts(ctx, service.BulkProductsRequest{})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrRequiredField("operations"), err)
+}
+
+func TestBulkProductsConcurrentStock(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+
+	product, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "A", Name: "Apple", Stock: ptr(100)})
+
+	// Bulk updates race stock adjustments, the ledger still has to add up
+	// to the stock.
+	const rounds = 20
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			_, _, err := s.BulkProducts(ctx, service.BulkProductsRequest{
+				Operations: []service.BulkProductOperation{
+					{Op: "update", ID: product.ID, Product: []byte(fmt.Sprintf(`{"stock":%d}`, 50+i))},
+				},
+			})
+			assert.NoError(t, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			_, _, err := s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: 1, Reason: "found"})
+			assert.NoError(t, err)
+		}
+	}()
+	wg.Wait()
+
+	current, _, _ := s.GetProduct(ctx, productID(product.ID))
+	movements, _, _, err := s.GetStockMovements(ctx, productID(product.ID), service.GetStockMovementsRequest{Page: 1, Limit: 100})
+	assert.NoError(t, err)
+
+	var stock int
+	for _, movement := range movements {
+		stock += movement.Delta
+	}
+	assert.Equal(t, current.Stock, stock)
+	assert.Equal(t, current.Stock, movements[0].Balance)
+}
+
+func TestImportProducts(t *testing.T) {
+	ctx := context.Background()
+	products := productsMemory.New()
+	s := newTestService(t, withProducts(products))
+
+	existing, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "A", Name: "Apple", Category: "fruit", Stock: ptr(5)})
+	legacy, _, _ := products.CreateProduct(ctx, entity.Products{Name: "Banana", Stock: 2})
+
+	file := "sku,name_product,stock,tags,price,currency\n" +
+		"A,Green Apple,7,fresh|green,120,usd\n" +
+		"B,banana,4,,,\n" +
+		"C,Cherry,x,,,\n" +
+		"D,Durian,3,,100,\n" +
+		"A,Apple Again,1,,,\n" +
+		"E,Elderberry,0,,,\n"
+
+	result, code, err := s.ImportProducts(ctx, service.ImportProductsRequest{File: strings.NewReader(file), DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, &service.ImportProductsResponse{
+		DryRun:  true,
+		Rows:    6,
+		Created: 1,
+		Updated: 2,
+		Failed:  3,
+		Errors: []*service.ImportRowError{
+			{Row: 4, SKU: "C", Error: errors.ErrInvalidImportValue("stock").Error()},
+			{Row: 5, SKU: "D", Error: errors.ErrRequiredWithField("currency", "price").Error()},
+			{Row: 6, SKU: "A", Error: errors.ErrDuplicateImportProduct.Error()},
+		},
+	}, result)
+
+	current, _, _ := s.GetProduct(ctx, productID(existing.ID))
+	assert.Equal(t, "Apple", current.Name)
+
+	result, _, err = s.ImportProducts(ctx, service.ImportProductsRequest{File: strings.NewReader(file)})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 2, result.Updated)
+
+	current, _, _ = s.GetProduct(ctx, productID(existing.ID))
+	assert.Equal(t, "Green Apple", current.Name)
+	assert.Equal(t, 7, current.Stock)
+	assert.Equal(t, "fruit", current.Category)
+	assert.Equal(t, []string{"fresh", "green"}, current.Tags)
+	assert.Equal(t, "USD", current.Currency)
+
+	current, _, _ = s.GetProduct(ctx, productID(legacy.ID))
+	assert.Equal(t, "B", current.SKU)
+	assert.Equal(t, 4, current.Stock)
+
+	movements, _, _, _ := s.GetStockMovements(ctx, productID(existing.ID), service.GetStockMovementsRequest{Page: 1, Limit: 10})
+	assert.Equal(t, 2, movements[0].Delta)
+	assert.Equal(t, "import", movements[0].Reason)
+
+	for name, test := range map[string]struct {
+		file        string
+		expectedErr error
+	}{
+		"empty":          {file: "", expectedErr: errors.ErrEmptyImportFile},
+		"unknown-column": {file: "sku,name_product,stock,color\n", expectedErr: errors.ErrUnknownImportColumn("color")},
+		"missing-column": {file: "sku,name_product\n", expectedErr: errors.ErrMissingImportColumn("stock")},
+		"ragged-row":     {file: "sku,name_product,stock\nA,Apple\n", expectedErr: errors.ErrInvalidImportLine(2)},
+	} {
+		_, code, err := s.ImportProducts(ctx, service.ImportProductsRequest{File: strings.NewReader(test.file)})
+		assert.Equal(t, http.StatusBadRequest, code, name)
+		assert.Equal(t, test.expectedErr, err, name)
+	}
+}
+
+// reservingProducts reserves stock of the products it finds by sku or
+// name right after reading them, as a concurrent reservation would.
+type reservingProducts struct {
+	productsRepo.Repository
+	quantity int
+}
+
+func (r *reservingProducts) GetProductsBySKUOrName(ctx context.Context, skus []string, names []string) ([]*entity.Products, int, error) {
+	products, code, err := r.Repository.GetProductsBySKUOrName(ctx, skus, names)
+	for _, product := range products {
+		if _, _, err := r.Repository.ReserveStock(ctx, entity.ProductID(product.ID), r.quantity); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+	return products, code, err
+}
+
+func TestImportProductsReservedSinceRead(t *testing.T) {
+	ctx := context.Background()
+	products := &reservingProducts{Repository: productsMemory.New(), quantity: 4}
+	s := newTestService(t, withProducts(products))
+
+	apple, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "A", Name: "Apple", Stock: ptr(10)})
+	banana, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "B", Name: "Banana", Stock: ptr(10)})
+
+	file := "sku,name_product,stock\n" +
+		"A,Green Apple,3\n" +
+		"B,Yellow Banana,6\n"
+
+	result, _, err := s.ImportProducts(ctx, service.ImportProductsRequest{File: strings.NewReader(file)})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, []*service.ImportRowError{
+		{Row: 2, SKU: "A", Error: errors.ErrInsufficientStock.Error()},
+	}, result.Errors, "stock below what was reserved after the read")
+
+	current, _, _ := s.GetProduct(ctx, productID(apple.ID))
+	assert.Equal(t, "Apple", current.Name)
+	assert.Equal(t, 10, current.Stock)
+	assert.Equal(t, 4, current.Reserved)
+
+	current, _, _ = s.GetProduct(ctx, productID(banana.ID))
+	assert.Equal(t, "Yellow Banana", current.Name)
+	assert.Equal(t, 6, current.Stock)
+	assert.Equal(t, 4, current.Reserved, "reservation made after the read is kept")
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	productsEntity "hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/domain/reservations/entity"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// expireReservationsBatch is how many stale reservations one sweep handles.
+const expireReservationsBatch = 100
+
+type Reservations struct {
+	ID        string    `json:"id"`
+	ProductID string    `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Status    string    `json:"status"`
+	Actor     string    `json:"actor"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func reservationFromEntity(reservation *entity.Reservations) *Reservations {
+	return &Reservations{
+		ID:        reservation.ID,
+		ProductID: reservation.ProductID,
+		Quantity:  reservation.Quantity,
+		Status:    reservation.Status,
+		Actor:     reservation.Actor,
+		ExpiresAt: reservation.ExpiresAt,
+		CreatedAt: reservation.CreatedAt,
+	}
+}
+
+type ReserveStockRequest struct {
+	Quantity int `json:"quantity" validate:"required,gt=0"`
+}
+
+func (s *service) ReserveStock(ctx context.Context, id productsEntity.ProductID, data ReserveStockRequest) (_ *Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ReserveStock")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ReserveStock", time.Now(), &code)
+
+	if err := utils.Validate(&data); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	// The stock is held and the reservation saved together, so no hold
+	// is left without a reservation to end it.
+	var reservation *entity.Reservations
+	code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+		if _, code, err := s.products.ReserveStock(ctx, id, data.Quantity); err != nil {
+			return code, err
+		}
+
+		now := time.Now()
+		created, code, err := s.reservations.CreateReservation(ctx, entity.Reservations{
+			ProductID: id.String(),
+			Quantity:  data.Quantity,
+			Status:    entity.StatusActive,
+			Actor:     utils.GetActor(ctx),
+			ExpiresAt: now.Add(s.cfg.ReservationTTL),
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+		reservation = created
+		return code, err
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	return reservationFromEntity(reservation), http.StatusOK, nil
+}
+
+func (s *service) GetReservation(ctx context.Context, id string) (_ *Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:GetReservation")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetReservation", time.Now(), &code)
+
+	reservation, code, err := s.reservations.GetReservationByID(ctx, id)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return reservationFromEntity(reservation), code, nil
+}
+
+func (s *service) ConfirmReservation(ctx context.Context, id string) (_ *Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ConfirmReservation")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ConfirmReservation", time.Now(), &code)
+
+	reservation, code, err := s.reservations.GetReservationByID(ctx, id)
+	if err != nil {
+		return nil, code, err
+	}
+
+	// The sweeper may not have caught up with an expired hold yet.
+	if reservation.Status == entity.StatusActive && !reservation.ExpiresAt.After(time.Now()) {
+		if _, code, err := s.finishReservation(ctx, id, entity.StatusExpired); err != nil {
+			return nil, code, err
+		}
+		return nil, http.StatusConflict, errors.ErrReservationExpired
+	}
+
+	// The status is claimed in the same transaction as the stock, so a
+	// concurrent release or expiry can't act on the same hold.
+	code, err = s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+		confirmed, code, err := s.reservations.UpdateReservationStatus(ctx, id, entity.StatusActive, entity.StatusConfirmed)
+		if err != nil {
+			return code, err
+		}
+		reservation = confirmed
+
+		product, taken, code, err := s.products.CommitReservedStock(ctx, productsEntity.ProductID(reservation.ProductID), reservation.Quantity)
+		if err != nil {
+			return code, err
+		}
+
+		// A leg for each warehouse the stock left, and one for the rest
+		// which was unallocated.
+		reason := fmt.Sprintf("reservation %s confirmed", reservation.ID)
+		unallocated := reservation.Quantity
+		for _, location := range taken {
+			if code, err := s.recordStockMovement(ctx, product, location.WarehouseID, -location.Stock, reason); err != nil {
+				return code, err
+			}
+			unallocated -= location.Stock
+		}
+		return s.recordStockMovement(ctx, product, "", -unallocated, reason)
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	return reservationFromEntity(reservation), http.StatusOK, nil
+}
+
+func (s *service) ReleaseReservation(ctx context.Context, id string) (_ *Reservations, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ReleaseReservation")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ReleaseReservation", time.Now(), &code)
+
+	reservation, code, err := s.finishReservation(ctx, id, entity.StatusReleased)
+	if err != nil {
+		return nil, code, err
+	}
+
+	return reservationFromEntity(reservation), code, nil
+}
+
+func (s *service) ExpireReservations(ctx context.Context) (_ int, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:ExpireReservations")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "ExpireReservations", time.Now(), &code)
+
+	reservations, code, err := s.reservations.GetExpiredReservations(ctx, time.Now(), expireReservationsBatch)
+	if err != nil {
+		return 0, code, err
+	}
+
+	// One failed reservation doesn't stop the sweep, the first failure is
+	// returned once the rest are done.
+	var expired int
+	var sweepCode int
+	var sweepErr error
+	for _, reservation := range reservations {
+		_, code, err := s.finishReservation(ctx, reservation.ID, entity.StatusExpired)
+		if err == errors.ErrReservationNotActive {
+			// Confirmed or released while sweeping.
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to expire reservation",
+				slog.String("reservation_id", reservation.ID),
+				slog.String("error", err.Error()),
+			)
+			if sweepErr == nil {
+				sweepCode, sweepErr = code, err
+			}
+			continue
+		}
+		expired++
+	}
+
+	if sweepErr != nil {
+		return expired, sweepCode, sweepErr
+	}
+
+	return expired, http.StatusOK, nil
+}
+
+// finishReservation to move an active reservation to a final status
+// and give its held stock back, in one transaction like
+// ConfirmReservation.
+func (s *service) finishReservation(ctx context.Context, id string, status string) (*entity.Reservations, int, error) {
+	var reservation *entity.Reservations
+	code, err := s.inTransaction(ctx, func(ctx context.Context) (int, error) {
+		finished, code, err := s.reservations.UpdateReservationStatus(ctx, id, entity.StatusActive, status)
+		if err != nil {
+			return code, err
+		}
+		reservation = finished
+
+		if _, code, err := s.products.ReleaseStock(ctx, productsEntity.ProductID(reservation.ProductID), reservation.Quantity); err != nil {
+			if code != http.StatusNotFound {
+				return code, err
+			}
+
+			// The product was purged along with the stock it held, so
+			// there is nothing to give back and trying again can't help.
+			// The hold ends as expired.
+			slog.WarnContext(ctx, "reservation product not found, expiring reservation",
+				slog.String("reservation_id", reservation.ID),
+				slog.String("product_id", reservation.ProductID),
+			)
+			if status != entity.StatusExpired {
+				reservation, code, err = s.reservations.UpdateReservationStatus(ctx, id, status, entity.StatusExpired)
+				return code, err
+			}
+		}
+
+		return http.StatusOK, nil
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	return reservation, http.StatusOK, nil
+}
@@ -0,0 +1,160 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	productsEntity "hexagon-architecture/internal/domain/products/entity"
+	productsRepo "hexagon-architecture/internal/domain/products/repository"
+	productsMemory "hexagon-architecture/internal/domain/products/repository/memory"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, withConfig(service.Config{ReservationTTL: time.Minute}))
+
+	product, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(5)})
+
+	first, code, err := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, code, err = s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 3})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrInsufficientStock, err)
+
+	_, code, _ = s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: -3, Reason: "sale"})
+	assert.Equal(t, http.StatusConflict, code)
+
+	current, _, _ := s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 5, current.Stock)
+	assert.Equal(t, 3, current.Reserved)
+	assert.Equal(t, 2, current.Available)
+
+	second, _, err := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 2})
+	assert.NoError(t, err)
+
+	_, _, err = s.ReleaseReservation(ctx, second.ID)
+	assert.NoError(t, err)
+
+	_, code, err = s.ReleaseReservation(ctx, second.ID)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrReservationNotActive, err)
+
+	confirmed, _, err := s.ConfirmReservation(ctx, first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "confirmed", confirmed.Status)
+
+	current, _, _ = s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 2, current.Stock)
+	assert.Equal(t, 0, current.Reserved)
+	assert.Equal(t, 2, current.Available)
+}
+
+func TestExpireReservations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, withConfig(service.Config{ReservationTTL: -time.Second}))
+
+	product, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(5)})
+
+	first, _, _ := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 2})
+	second, _, _ := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 2})
+
+	_, code, err := s.ConfirmReservation(ctx, first.ID)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrReservationExpired, err)
+
+	expired, _, err := s.ExpireReservations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, expired)
+
+	reservation, _, _ := s.GetReservation(ctx, second.ID)
+	assert.Equal(t, "expired", reservation.Status)
+
+	current, _, _ := s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 5, current.Available)
+}
+
+// failingStockChanges fails to commit or release reserved stock while
+// fail is set, and releases as if the product was purged while missing
+// is set.
+type failingStockChanges struct {
+	productsRepo.Repository
+	fail    bool
+	missing bool
+}
+
+func (f *failingStockChanges) CommitReservedStock(ctx context.Context, id productsEntity.ProductID, quantity int) (*productsEntity.Products, []productsEntity.Locations, int, error) {
+	if f.fail {
+		return nil, nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	return f.Repository.CommitReservedStock(ctx, id, quantity)
+}
+
+func (f *failingStockChanges) ReleaseStock(ctx context.Context, id productsEntity.ProductID, quantity int) (*productsEntity.Products, int, error) {
+	if f.fail {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	if f.missing {
+		return nil, http.StatusNotFound, errors.ErrNotFoundProduct
+	}
+	return f.Repository.ReleaseStock(ctx, id, quantity)
+}
+
+func TestReservationStockChangeFails(t *testing.T) {
+	ctx := context.Background()
+	products := &failingStockChanges{Repository: productsMemory.New()}
+	s := newTestService(t, withProducts(products), withConfig(service.Config{ReservationTTL: time.Minute}))
+
+	product, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(5)})
+	reservation, _, err := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 2})
+	assert.NoError(t, err)
+
+	products.fail = true
+	_, code, err := s.ConfirmReservation(ctx, reservation.ID)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, errors.ErrInternalDB, err)
+
+	_, code, err = s.ReleaseReservation(ctx, reservation.ID)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, errors.ErrInternalDB, err)
+
+	current, _, _ := s.GetReservation(ctx, reservation.ID)
+	assert.Equal(t, "active", current.Status, "status change is rolled back with the stock")
+
+	products.fail = false
+	confirmed, code, err := s.ConfirmReservation(ctx, reservation.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "confirmed", confirmed.Status)
+
+	stocked, _, _ := s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 3, stocked.Stock)
+	assert.Equal(t, 0, stocked.Reserved)
+}
+
+func TestReleaseReservationProductMissing(t *testing.T) {
+	ctx := context.Background()
+	products := &failingStockChanges{Repository: productsMemory.New()}
+	s := newTestService(t, withProducts(products), withConfig(service.Config{ReservationTTL: time.Minute}))
+
+	product, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(5)})
+	reservation, _, err := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 2})
+	assert.NoError(t, err)
+
+	products.missing = true
+	released, code, err := s.ReleaseReservation(ctx, reservation.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "expired", released.Status, "a hold on a missing product ends as expired")
+
+	_, code, err = s.ReleaseReservation(ctx, reservation.ID)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrReservationNotActive, err)
+}
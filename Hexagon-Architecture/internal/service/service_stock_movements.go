@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	productsEntity "hexagon-architecture/internal/domain/products/entity"
+	"hexagon-architecture/internal/domain/stockmovements/entity"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"time"
+)
+
+type StockMovements struct {
+	ID            string    `json:"id"`
+	ProductID     string    `json:"product_id"`
+	WarehouseID   string    `json:"warehouse_id,omitempty"`
+	Delta         int       `json:"delta"`
+	Balance       int       `json:"balance"`
+	Reason        string    `json:"reason"`
+	Actor         string    `json:"actor"`
+	CorrelationID string    `json:"correlation_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type GetStockMovementsRequest struct {
+	Page  int
+	Limit int
+}
+
+func (s *service) GetStockMovements(ctx context.Context, productID productsEntity.ProductID, req GetStockMovementsRequest) (_ []*StockMovements, _ *utils.Pagination, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:GetStockMovements")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetStockMovements", time.Now(), &code)
+
+	movements, pagination, code, err := s.stockMovements.GetStockMovements(ctx, entity.GetStockMovementsRequest{
+		ProductID: productID.String(),
+		Page:      req.Page,
+		Limit:     req.Limit,
+	})
+	if err != nil {
+		return nil, nil, code, err
+	}
+
+	movementsDTO := make([]*StockMovements, len(movements))
+	for i, movement := range movements {
+		movementsDTO[i] = &StockMovements{
+			ID:            movement.ID,
+			ProductID:     movement.ProductID,
+			WarehouseID:   movement.WarehouseID,
+			Delta:         movement.Delta,
+			Balance:       movement.Balance,
+			Reason:        movement.Reason,
+			Actor:         movement.Actor,
+			CorrelationID: movement.CorrelationID,
+			CreatedAt:     movement.CreatedAt,
+		}
+	}
+
+	return movementsDTO, pagination, code, nil
+}
+
+// recordStockMovement to append a stock change to the ledger. The
+// balance is the stock of the warehouse moved in or out of, or the product
+// stock when there is none. It is called in the transaction changing the
+// stock, so the change is undone if it can't be recorded.
+func (s *service) recordStockMovement(ctx context.Context, product *productsEntity.Products, warehouseID string, delta int, reason string) (int, error) {
+	balance := product.Stock
+	if warehouseID != "" {
+		balance = product.LocationStock(warehouseID)
+	}
+
+	return s.saveStockMovement(ctx, product, warehouseID, delta, balance, reason)
+}
+
+// recordTransferLeg to append one leg of a stock transfer to the ledger.
+// The balance is the stock of the warehouse, or the unallocated stock when
+// there is none.
+func (s *service) recordTransferLeg(ctx context.Context, product *productsEntity.Products, warehouseID string, delta int, reason string) (int, error) {
+	balance := product.Unallocated()
+	if warehouseID != "" {
+		balance = product.LocationStock(warehouseID)
+	}
+
+	return s.saveStockMovement(ctx, product, warehouseID, delta, balance, reason)
+}
+
+// saveStockMovement to append a stock movement with the given balance.
+func (s *service) saveStockMovement(ctx context.Context, product *productsEntity.Products, warehouseID string, delta int, balance int, reason string) (int, error) {
+	if delta == 0 {
+		return http.StatusOK, nil
+	}
+
+	_, code, err := s.stockMovements.CreateStockMovement(ctx, entity.StockMovements{
+		ID:            entity.NewID(),
+		ProductID:     product.ID,
+		WarehouseID:   warehouseID,
+		Delta:         delta,
+		Balance:       balance,
+		Reason:        reason,
+		Actor:         utils.GetActor(ctx),
+		CorrelationID: utils.GetCorrelationID(ctx),
+		CreatedAt:     time.Now(),
+	})
+	return code, err
+}
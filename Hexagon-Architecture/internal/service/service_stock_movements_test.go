@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	stockMovementsEntity "hexagon-architecture/internal/domain/stockmovements/entity"
+	stockMovementsRepo "hexagon-architecture/internal/domain/stockmovements/repository"
+	stockMovementsMemory "hexagon-architecture/internal/domain/stockmovements/repository/memory"
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStockMovements(t *testing.T) {
+	ctx := utils.SetActor(context.Background(), "clerk")
+	ctx = utils.SetCorrelationID(ctx, "req-1")
+
+	s := newTestService(t)
+
+	product, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(10)})
+	assert.NoError(t, err)
+
+	_, code, err := s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: -4, Reason: "sale"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, code, err = s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: -7, Reason: "sale"})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Error(t, err)
+
+	stock := 9
+	_, _, err = s.UpdateProduct(ctx, productID(product.ID), service.UpdateProductRequest{Stock: &stock})
+	assert.NoError(t, err)
+
+	name := "Green Apple"
+	_, _, err = s.UpdateProduct(ctx, productID(product.ID), service.UpdateProductRequest{Name: &name})
+	assert.NoError(t, err)
+
+	movements, pagination, code, err := s.GetStockMovements(ctx, productID(product.ID), service.GetStockMovementsRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 3, pagination.Total)
+
+	type movement struct {
+		Delta, Balance int
+		Reason         string
+	}
+	var got []movement
+	for _, m := range movements {
+		assert.Equal(t, "clerk", m.Actor)
+		assert.Equal(t, "req-1", m.CorrelationID)
+		got = append(got, movement{m.Delta, m.Balance, m.Reason})
+	}
+	assert.Equal(t, []movement{
+		{Delta: 3, Balance: 9, Reason: "update"},
+		{Delta: -4, Balance: 6, Reason: "sale"},
+		{Delta: 10, Balance: 10, Reason: "create"},
+	}, got)
+}
+
+// failingStockMovements fails to record movements while fail is set.
+type failingStockMovements struct {
+	stockMovementsRepo.Repository
+	fail bool
+}
+
+func (f *failingStockMovements) CreateStockMovement(ctx context.Context, movement stockMovementsEntity.StockMovements) (*stockMovementsEntity.StockMovements, int, error) {
+	if f.fail {
+		return nil, http.StatusInternalServerError, errors.ErrInternalDB
+	}
+	return f.Repository.CreateStockMovement(ctx, movement)
+}
+
+func TestStockMovementsFail(t *testing.T) {
+	ctx := context.Background()
+	movementsRepo := &failingStockMovements{Repository: stockMovementsMemory.New(), fail: true}
+	s := newTestService(t, withStockMovements(movementsRepo))
+
+	_, code, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(10)})
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, errors.ErrInternalDB, err)
+
+	products, _, _, _ := s.GetProducts(ctx, service.GetProductsRequest{Page: 1, Limit: 10})
+	assert.Empty(t, products, "product isn't created without its movement")
+
+	movementsRepo.fail = false
+	product, _, err := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(10)})
+	assert.NoError(t, err)
+
+	movementsRepo.fail = true
+	_, code, err = s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: -4, Reason: "sale"})
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, errors.ErrInternalDB, err)
+
+	stock := 3
+	_, code, _ = s.UpdateProduct(ctx, productID(product.ID), service.UpdateProductRequest{Stock: &stock})
+	assert.Equal(t, http.StatusInternalServerError, code)
+
+	current, _, _ := s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 10, current.Stock, "stock change is rolled back with its movement")
+	assert.Equal(t, 1, current.Version)
+
+	movements, _, _, err := s.GetStockMovements(ctx, productID(product.ID), service.GetStockMovementsRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 1)
+}
+
+func TestCreateStockMovementTwice(t *testing.T) {
+	ctx := context.Background()
+	movementsRepo := stockMovementsMemory.New()
+
+	movement := stockMovementsEntity.StockMovements{
+		ID:        stockMovementsEntity.NewID(),
+		ProductID: stockMovementsEntity.NewID(),
+		Delta:     1,
+		Balance:   1,
+	}
+	for i := 0; i < 2; i++ {
+		_, code, err := movementsRepo.CreateStockMovement(ctx, movement)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	_, pagination, _, err := movementsRepo.GetStockMovements(ctx, stockMovementsEntity.GetStockMovementsRequest{ProductID: movement.ProductID, Page: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pagination.Total, "a retried movement is recorded once")
+}
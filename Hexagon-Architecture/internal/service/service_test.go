@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"testing"
+
+	idempotencyKeysMemory "hexagon-architecture/internal/domain/idempotencykeys/repository/memory"
+	productsEntity "hexagon-architecture/internal/domain/products/entity"
+	productsRepo "hexagon-architecture/internal/domain/products/repository"
+	productsMemory "hexagon-architecture/internal/domain/products/repository/memory"
+	reservationsMemory "hexagon-architecture/internal/domain/reservations/repository/memory"
+	stockMovementsRepo "hexagon-architecture/internal/domain/stockmovements/repository"
+	stockMovementsMemory "hexagon-architecture/internal/domain/stockmovements/repository/memory"
+	warehousesMemory "hexagon-architecture/internal/domain/warehouses/repository/memory"
+	"hexagon-architecture/internal/service"
+	"hexagon-architecture/pkg/transaction"
+)
+
+// testService is what newTestService builds a service from.
+type testService struct {
+	products       productsRepo.Repository
+	stockMovements stockMovementsRepo.Repository
+	cfg            service.Config
+}
+
+// newTestService returns a service on fresh memory repositories, changed
+// by options.
+func newTestService(t *testing.T, options ...func(*testService)) service.Service {
+	t.Helper()
+
+	ts := testService{
+		products:       productsMemory.New(),
+		stockMovements: stockMovementsMemory.New(),
+	}
+	for _, option := range options {
+		option(&ts)
+	}
+
+	// Test doubles wrapping a repository aren't rolled back.
+	reservations := reservationsMemory.New()
+	storage := []transaction.Snapshotter{reservations}
+	for _, repo := range []any{ts.products, ts.stockMovements} {
+		if s, ok := repo.(transaction.Snapshotter); ok {
+			storage = append(storage, s)
+		}
+	}
+
+	return service.New(ts.products, ts.stockMovements, reservations, warehousesMemory.New(), idempotencyKeysMemory.New(), transaction.NewMemory(storage...), ts.cfg)
+}
+
+// withProducts runs the test service on products.
+func withProducts(products productsRepo.Repository) func(*testService) {
+	return func(ts *testService) { ts.products = products }
+}
+
+// withStockMovements runs the test service on stockMovements.
+func withStockMovements(stockMovements stockMovementsRepo.Repository) func(*testService) {
+	return func(ts *testService) { ts.stockMovements = stockMovements }
+}
+
+// withConfig runs the test service with cfg.
+func withConfig(cfg service.Config) func(*testService) {
+	return func(ts *testService) { ts.cfg = cfg }
+}
+
+// productID is the typed id of a product the test service returned.
+func productID(id string) productsEntity.ProductID {
+	return productsEntity.ProductID(id)
+}
+
+// ptr returns a pointer to v, for optional request fields.
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"hexagon-architecture/internal/domain/warehouses/entity"
+	"hexagon-architecture/internal/infrastructure"
+	"hexagon-architecture/internal/utils"
+	"net/http"
+	"time"
+)
+
+type Warehouses struct {
+	ID        string    `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func warehouseFromEntity(warehouse *entity.Warehouses) *Warehouses {
+	return &Warehouses{
+		ID:        warehouse.ID,
+		Code:      warehouse.Code,
+		Name:      warehouse.Name,
+		CreatedAt: warehouse.CreatedAt,
+	}
+}
+
+type CreateWarehouseRequest struct {
+	Code string `json:"code" validate:"required" mod:"no_space"`
+	Name string `json:"name" validate:"required"`
+}
+
+func (s *service) CreateWarehouse(ctx context.Context, data CreateWarehouseRequest) (_ *Warehouses, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:CreateWarehouse")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "CreateWarehouse", time.Now(), &code)
+
+	if err := utils.Validate(&data); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	warehouse, code, err := s.warehouses.CreateWarehouse(ctx, entity.Warehouses{
+		Code:      data.Code,
+		Name:      data.Name,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, code, err
+	}
+
+	return warehouseFromEntity(warehouse), code, nil
+}
+
+func (s *service) GetWarehouses(ctx context.Context) (_ []*Warehouses, code int, _ error) {
+	_, span := infrastructure.Tracer().Start(ctx, "service:GetWarehouses")
+	defer span.End()
+	defer infrastructure.ObserveService(ctx, "GetWarehouses", time.Now(), &code)
+
+	warehouses, code, err := s.warehouses.GetWarehouses(ctx)
+	if err != nil {
+		return nil, code, err
+	}
+
+	warehousesDTO := make([]*Warehouses, len(warehouses))
+	for i, warehouse := range warehouses {
+		warehousesDTO[i] = warehouseFromEntity(warehouse)
+	}
+
+	return warehousesDTO, code, nil
+}
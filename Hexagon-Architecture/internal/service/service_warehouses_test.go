@@ -0,0 +1,88 @@
+package service_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarehouseStock(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t, withConfig(service.Config{ReservationTTL: time.Minute}))
+
+	north, _, _ := s.CreateWarehouse(ctx, service.CreateWarehouseRequest{Code: "N", Name: "North"})
+	south, _, _ := s.CreateWarehouse(ctx, service.CreateWarehouseRequest{Code: "S", Name: "South"})
+
+	_, code, err := s.CreateWarehouse(ctx, service.CreateWarehouseRequest{Code: "N", Name: "Northeast"})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrDuplicateWarehouseCode, err)
+
+	product, _, _ := s.CreateProduct(ctx, service.CreateProductRequest{SKU: "APL-1", Name: "Apple", Stock: ptr(10)})
+
+	_, code, err = s.TransferStock(ctx, productID(product.ID), service.TransferStockRequest{ToWarehouseID: north.ID, Quantity: 6, Reason: "put away"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, _, err = s.TransferStock(ctx, productID(product.ID), service.TransferStockRequest{FromWarehouseID: north.ID, ToWarehouseID: south.ID, Quantity: 2, Reason: "rebalance"})
+	assert.NoError(t, err)
+
+	_, code, err = s.TransferStock(ctx, productID(product.ID), service.TransferStockRequest{FromWarehouseID: north.ID, ToWarehouseID: north.ID, Quantity: 1, Reason: "noop"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, errors.ErrSameTransferLocation, err)
+
+	_, code, err = s.TransferStock(ctx, productID(product.ID), service.TransferStockRequest{FromWarehouseID: north.ID, ToWarehouseID: south.ID, Quantity: 5, Reason: "rebalance"})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, errors.ErrInsufficientStock, err)
+
+	_, _, err = s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: -1, Reason: "damaged", WarehouseID: south.ID})
+	assert.NoError(t, err)
+
+	_, code, _ = s.AdjustStock(ctx, productID(product.ID), service.AdjustStockRequest{Delta: -5, Reason: "sale"})
+	assert.Equal(t, http.StatusConflict, code)
+
+	current, _, _ := s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 9, current.Stock)
+	assert.Equal(t, 4, current.Unallocated)
+	assert.Equal(t, []*service.ProductLocations{
+		{WarehouseID: north.ID, WarehouseName: "North", Stock: 4},
+		{WarehouseID: south.ID, WarehouseName: "South", Stock: 1},
+	}, current.Locations)
+
+	reservation, _, _ := s.ReserveStock(ctx, productID(product.ID), service.ReserveStockRequest{Quantity: 6})
+	_, _, err = s.ConfirmReservation(ctx, reservation.ID)
+	assert.NoError(t, err)
+
+	current, _, _ = s.GetProduct(ctx, productID(product.ID))
+	assert.Equal(t, 3, current.Stock)
+	assert.Equal(t, 0, current.Unallocated)
+	assert.Equal(t, 2, current.Locations[0].Stock)
+	assert.Equal(t, 1, current.Locations[1].Stock)
+
+	movements, _, _, err := s.GetStockMovements(ctx, productID(product.ID), service.GetStockMovementsRequest{Page: 1, Limit: 10})
+	assert.NoError(t, err)
+
+	type movement struct {
+		WarehouseID    string
+		Delta, Balance int
+	}
+	var got []movement
+	for _, m := range movements {
+		got = append(got, movement{m.WarehouseID, m.Delta, m.Balance})
+	}
+	assert.Equal(t, []movement{
+		{WarehouseID: "", Delta: -4, Balance: 3},
+		{WarehouseID: north.ID, Delta: -2, Balance: 2},
+		{WarehouseID: south.ID, Delta: -1, Balance: 1},
+		{WarehouseID: south.ID, Delta: 2, Balance: 2},
+		{WarehouseID: north.ID, Delta: -2, Balance: 4},
+		{WarehouseID: north.ID, Delta: 6, Balance: 6},
+		{WarehouseID: "", Delta: -6, Balance: 4},
+		{WarehouseID: "", Delta: 10, Balance: 10},
+	}, got, "warehouse legs carry the warehouse balance, unallocated transfer legs the unallocated stock")
+}
@@ -0,0 +1,37 @@
+package utils
+
+import "context"
+
+type contextKey string
+
+const (
+	actorKey         contextKey = "actor"
+	correlationIDKey contextKey = "correlation_id"
+)
+
+// DefaultActor is actor name used when request doesn't identify its user.
+const DefaultActor = "anonymous"
+
+// SetActor to put the user who makes the request in context.
+func SetActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// GetActor to get the user who makes the request from context.
+func GetActor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return DefaultActor
+}
+
+// SetCorrelationID to put the id tying together everything a request does in context.
+func SetCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, id)
+}
+
+// GetCorrelationID to get request correlation id from context.
+func GetCorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey).(string)
+	return id
+}
@@ -13,6 +13,7 @@ type Response struct {
 	Message    string      `json:"message"`
 	Data       interface{} `json:"data" swaggertype:"object"`
 	Pagination *Pagination `json:"pagination,omitempty"`
+	Cursor     *Cursor     `json:"cursor,omitempty"`
 }
 
 // Pagination is pagination response model.
@@ -23,6 +24,43 @@ type Pagination struct {
 	LastPage    int `json:"last_page"`
 }
 
+// Cursor is cursor pagination response model.
+// Empty next cursor means there is no more data.
+type Cursor struct {
+	NextCursor string `json:"next_cursor"`
+	Limit      int    `json:"limit"`
+}
+
+// responseStatusKey is the fiber local keeping the status written in the
+// response body.
+const responseStatusKey = "response_status"
+
+// ResponseStatus returns the status written in the response body by
+// ResponseWithJSON or ResponseWithCursor, 0 if neither was used.
+func ResponseStatus(c *fiber.Ctx) int {
+	code, _ := c.Locals(responseStatusKey).(int)
+	return code
+}
+
+// ResponseWithCursor to write response with JSON format and cursor pagination.
+func ResponseWithCursor(c *fiber.Ctx, code int, data interface{}, err error, cursor *Cursor) {
+	r := Response{
+		Status:  code,
+		Message: strings.ToLower(http.StatusText(code)),
+		Data:    data,
+		Cursor:  cursor,
+	}
+	if err != nil {
+		r.Message = err.Error()
+	}
+
+	// Set response header.
+	c.Accepts("application/json")
+	c.Locals(responseStatusKey, code)
+
+	_ = c.JSON(r)
+}
+
 // ResponseWithJSON to write response with JSON format.
 func ResponseWithJSON(c *fiber.Ctx, code int, data interface{}, err error, pagination ...*Pagination) {
 	r := Response{
@@ -47,6 +85,7 @@ func ResponseWithJSON(c *fiber.Ctx, code int, data interface{}, err error, pagin
 
 	// Set response header.
 	c.Accepts("application/json")
+	c.Locals(responseStatusKey, code)
 
 	_ = c.JSON(r)
 }
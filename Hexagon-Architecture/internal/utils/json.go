@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"hexagon-architecture/internal/errors"
+)
+
+// DecodeJSON to decode data into v, rejecting unknown fields and anything
+// after the first JSON value. Failures are ErrInvalidRequestFormat with
+// the field and offset at fault.
+func DecodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return decodeError(data, err, decoder.InputOffset())
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.ErrInvalidRequestFormatAt("", decoder.InputOffset())
+	}
+
+	return nil
+}
+
+func decodeError(data []byte, err error, offset int64) error {
+	if err == io.ErrUnexpectedEOF {
+		return errors.ErrInvalidRequestFormatAt("", int64(len(data)))
+	}
+
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return errors.ErrInvalidRequestFormatAt("", e.Offset)
+	case *json.UnmarshalTypeError:
+		return errors.ErrInvalidRequestFormatAt(e.Field, e.Offset)
+	}
+
+	// encoding/json has no error type for unknown fields, only the message
+	// json: unknown field "name".
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		if unquoted, err := strconv.Unquote(field); err == nil {
+			field = unquoted
+		}
+		return errors.ErrInvalidRequestFormatAt(field, keyOffset(data, field, offset))
+	}
+
+	return errors.ErrInvalidRequestFormatAt("", offset)
+}
+
+// keyOffset returns where key first appears as an object key in data, or
+// fallback if it can't be found.
+func keyOffset(data []byte, key string, fallback int64) int64 {
+	quoted, err := json.Marshal(key)
+	if err != nil {
+		return fallback
+	}
+
+	for start := 0; ; {
+		i := bytes.Index(data[start:], quoted)
+		if i < 0 {
+			return fallback
+		}
+		i += start
+		start = i + len(quoted)
+
+		if rest := bytes.TrimLeft(data[start:], " \t\r\n"); len(rest) > 0 && rest[0] == ':' {
+			return int64(i)
+		}
+	}
+}
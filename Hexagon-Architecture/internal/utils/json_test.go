@@ -0,0 +1,63 @@
+package utils_test
+
+import (
+	"testing"
+
+	"hexagon-architecture/internal/errors"
+	"hexagon-architecture/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type dummyBody struct {
+	Name  string `json:"name"`
+	Stock int    `json:"stock"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := map[string]struct {
+		input       string
+		expected    dummyBody
+		expectedErr error
+	}{
+		"ok": {
+			input:    `{"name":"apple","stock":3}`,
+			expected: dummyBody{Name: "apple", Stock: 3},
+		},
+		"syntax": {
+			input:       `{"name":"apple",}`,
+			expectedErr: errors.ErrInvalidRequestFormatAt("", 17),
+		},
+		"wrong-type": {
+			input:       `{"name":"apple","stock":"3"}`,
+			expectedErr: errors.ErrInvalidRequestFormatAt("stock", 27),
+		},
+		"unknown-field": {
+			input:       `{"name":"apple","stok":3}`,
+			expectedErr: errors.ErrInvalidRequestFormatAt("stok", 16),
+		},
+		"trailing-data": {
+			input:       `{"name":"apple"} {}`,
+			expectedErr: errors.ErrInvalidRequestFormatAt("", 18),
+		},
+		"empty": {
+			input:       ``,
+			expectedErr: errors.ErrInvalidRequestFormatAt("", 0),
+		},
+		"truncated": {
+			input:       `{"name":"ap`,
+			expectedErr: errors.ErrInvalidRequestFormatAt("", 11),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var body dummyBody
+			err := utils.DecodeJSON([]byte(test.input), &body)
+			assert.Equal(t, test.expectedErr, err)
+			if test.expectedErr == nil {
+				assert.Equal(t, test.expected, body)
+			}
+		})
+	}
+}
@@ -16,6 +16,8 @@ func init() {
 	val.RegisterModifier("no_space", modNoSpace)
 	val.RegisterValidator("alpha", valAlpha)
 	val.RegisterValidatorError("required", valErrRequired)
+	val.RegisterValidatorError("required_with", valErrRequiredWith)
+	val.RegisterValidatorError("required_unless", valErrRequiredUnless)
 	val.RegisterValidatorError("gte", valErrGTE)
 	val.RegisterValidatorError("gt", valErrGT)
 	val.RegisterValidatorError("lte", valErrLTE)
@@ -25,6 +27,9 @@ func init() {
 	val.RegisterValidatorError("url", valErrURL)
 	val.RegisterValidatorError("oneof", valErrOneOf)
 	val.RegisterValidatorError("iso3166_1_alpha2", valErrISO3166)
+	val.RegisterValidatorError("iso4217", valErrISO4217)
+	val.RegisterValidatorError("min", valErrMin)
+	val.RegisterValidatorError("max", valErrMax)
 	val.RegisterValidatorError("numeric", valErrNumeric)
 	val.RegisterValidatorError("alpha", valErrAlpha)
 }
@@ -47,6 +52,15 @@ func valErrRequired(f string, param ...string) error {
 	return errors.ErrRequiredField(camelToSnake(f))
 }
 
+func valErrRequiredWith(f string, param ...string) error {
+	return errors.ErrRequiredWithField(camelToSnake(f), camelToSnake(param[0]))
+}
+
+func valErrRequiredUnless(f string, param ...string) error {
+	field, value, _ := strings.Cut(param[0], " ")
+	return errors.ErrRequiredUnlessField(camelToSnake(f), camelToSnake(field), value)
+}
+
 func valErrGTE(f string, param ...string) error {
 	return errors.ErrGTEField(camelToSnake(f), param[0])
 }
@@ -83,6 +97,18 @@ func valErrISO3166(f string, param ...string) error {
 	return errors.ErrISO3166Alpha2Field(camelToSnake(f))
 }
 
+func valErrISO4217(f string, param ...string) error {
+	return errors.ErrISO4217Field(camelToSnake(f))
+}
+
+func valErrMin(f string, param ...string) error {
+	return errors.ErrMinField(camelToSnake(f), param[0])
+}
+
+func valErrMax(f string, param ...string) error {
+	return errors.ErrMaxField(camelToSnake(f), param[0])
+}
+
 func valErrNumeric(f string, param ...string) error {
 	return errors.ErrNumericField(camelToSnake(f))
 }
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache is an in-process key value cache whose entries expire.
+type Cache[V any] interface {
+	Get(key string) (V, bool)
+	Set(key string, value V)
+}
+
+type cache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]entry[V]
+	cfg     Config
+}
+
+type entry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// Config is cache config.
+type Config struct {
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// New to create new cache.
+func New[V any](cfg Config) Cache[V] {
+	return &cache[V]{
+		entries: make(map[string]entry[V]),
+		cfg:     cfg,
+	}
+}
+
+// Get returns value of key if it is not expired yet.
+func (c *cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expiresAt) {
+		var zero V
+		return zero, false
+	}
+
+	return e.value, true
+}
+
+// Set stores value of key. When the cache is full, expired entries are
+// dropped first, then whichever entry expires soonest.
+func (c *cache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if _, ok := c.entries[key]; !ok && c.cfg.MaxEntries > 0 && len(c.entries) >= c.cfg.MaxEntries {
+		var oldest string
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+				continue
+			}
+			if oldest == "" || e.expiresAt.Before(c.entries[oldest].expiresAt) {
+				oldest = k
+			}
+		}
+		if len(c.entries) >= c.cfg.MaxEntries {
+			delete(c.entries, oldest)
+		}
+	}
+
+	c.entries[key] = entry[V]{
+		value:     value,
+		expiresAt: now.Add(c.cfg.TTL),
+	}
+}
@@ -0,0 +1,42 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"hexagon-architecture/pkg/cache"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := cache.New[int](cache.Config{TTL: time.Minute, MaxEntries: 2})
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("a", 3)
+	c.Set("c", 4)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok, "entry expiring soonest is evicted when full")
+
+	value, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 3, value)
+
+	value, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 4, value)
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c := cache.New[string](cache.Config{TTL: -time.Second})
+
+	c.Set("a", "apple")
+
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+}
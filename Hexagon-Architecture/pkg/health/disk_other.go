@@ -0,0 +1,17 @@
+//go:build !(linux || darwin)
+
+package health
+
+import (
+	"context"
+	"os"
+)
+
+// DiskSpace to check path exists. Free space is not checked on this
+// platform.
+func DiskSpace(path string, minFree uint64) Checker {
+	return func(ctx context.Context) error {
+		_, err := os.Stat(path)
+		return err
+	}
+}
@@ -0,0 +1,27 @@
+//go:build linux || darwin
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+)
+
+// DiskSpace to check the filesystem holding path has at least minFree
+// bytes available.
+func DiskSpace(path string, minFree uint64) Checker {
+	return func(ctx context.Context) error {
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(path, &stat); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		free := uint64(stat.Bavail) * uint64(stat.Bsize)
+		if free < minFree {
+			return fmt.Errorf("%s has %d bytes free, want at least %d", path, free, minFree)
+		}
+
+		return nil
+	}
+}
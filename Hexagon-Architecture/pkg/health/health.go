@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Checker checks a dependency. It returns nil if the dependency is healthy.
+type Checker func(ctx context.Context) error
+
+// Health runs registered checkers to tell whether the service is ready.
+type Health interface {
+	Register(name string, critical bool, checker Checker)
+	Check(ctx context.Context) Report
+	Drain()
+}
+
+// Config is health config.
+type Config struct {
+	// Timeout is how long each checker may take.
+	Timeout time.Duration
+}
+
+// Check status list.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Report is the result of a readiness check. The service is ready when it
+// is not draining and no critical check fails.
+type Report struct {
+	Ready    bool          `json:"ready"`
+	Draining bool          `json:"draining"`
+	Checks   []CheckResult `json:"checks"`
+}
+
+// CheckResult is the result of a checker.
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	checker  Checker
+}
+
+type health struct {
+	mu       sync.RWMutex
+	checks   []check
+	draining atomic.Bool
+	cfg      Config
+}
+
+// New to create new health.
+func New(cfg Config) Health {
+	return &health{
+		cfg: cfg,
+	}
+}
+
+// Register to add a checker. A failing critical check makes the service
+// not ready, other checks are only reported.
+func (h *health) Register(name string, critical bool, checker Checker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, check{
+		name:     name,
+		critical: critical,
+		checker:  checker,
+	})
+}
+
+// Check to run all checkers concurrently. Results keep the order the
+// checkers were registered in.
+func (h *health) Check(ctx context.Context) Report {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{
+		Ready:    !h.draining.Load(),
+		Draining: h.draining.Load(),
+		Checks:   results,
+	}
+
+	for _, result := range results {
+		if result.Critical && result.Status != StatusOK {
+			report.Ready = false
+		}
+	}
+
+	return report
+}
+
+func (h *health) run(ctx context.Context, c check) CheckResult {
+	if h.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.cfg.Timeout)
+		defer cancel()
+	}
+
+	result := CheckResult{
+		Name:     c.name,
+		Status:   StatusOK,
+		Critical: c.critical,
+	}
+
+	startTime := time.Now()
+
+	// The checker runs on its own so one that ignores ctx can't hold the
+	// whole report past the timeout.
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- c.checker(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errChan:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result.Duration = time.Since(startTime).String()
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// Drain to mark the service as not ready, e.g. when shutting down, so
+// load balancers stop sending new requests.
+func (h *health) Drain() {
+	h.draining.Store(true)
+}
@@ -0,0 +1,75 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"hexagon-architecture/pkg/health"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	h := health.New(health.Config{Timeout: 50 * time.Millisecond})
+
+	report := h.Check(context.Background())
+	assert.True(t, report.Ready, "ready without checkers")
+	assert.Empty(t, report.Checks)
+
+	failing := false
+	h.Register("db", true, func(ctx context.Context) error {
+		if failing {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	h.Register("exporter", false, func(ctx context.Context) error {
+		return errors.New("unavailable")
+	})
+
+	report = h.Check(context.Background())
+	assert.True(t, report.Ready, "non critical failure is only reported")
+	assert.Equal(t, "db", report.Checks[0].Name)
+	assert.Equal(t, health.StatusOK, report.Checks[0].Status)
+	assert.Equal(t, "exporter", report.Checks[1].Name)
+	assert.Equal(t, health.StatusFail, report.Checks[1].Status)
+	assert.Equal(t, "unavailable", report.Checks[1].Error)
+
+	failing = true
+	report = h.Check(context.Background())
+	assert.False(t, report.Ready)
+	assert.Equal(t, "connection refused", report.Checks[0].Error)
+
+	failing = false
+	h.Drain()
+	report = h.Check(context.Background())
+	assert.False(t, report.Ready)
+	assert.True(t, report.Draining)
+	assert.Equal(t, health.StatusOK, report.Checks[0].Status)
+}
+
+func TestHealthTimeout(t *testing.T) {
+	h := health.New(health.Config{Timeout: 20 * time.Millisecond})
+
+	block := make(chan struct{})
+	defer close(block)
+	h.Register("stuck", true, func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+
+	startTime := time.Now()
+	report := h.Check(context.Background())
+	assert.Less(t, time.Since(startTime), time.Second)
+	assert.False(t, report.Ready)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestDiskSpace(t *testing.T) {
+	assert.NoError(t, health.DiskSpace(t.TempDir(), 0)(context.Background()))
+	assert.Error(t, health.DiskSpace("/does/not/exist", 0)(context.Background()))
+	assert.Error(t, health.DiskSpace(t.TempDir(), math.MaxUint64)(context.Background()))
+}
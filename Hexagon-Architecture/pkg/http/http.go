@@ -1,8 +1,6 @@
 package http
 
 import (
-	"context"
-	"net/http"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,7 +14,6 @@ type Server interface {
 }
 
 type server struct {
-	server *http.Server
 	router *fiber.App
 	cfg    Config
 }
@@ -27,13 +24,20 @@ type Config struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	GracefulTimeout time.Duration
+	// BodyLimit is the largest request body read, in bytes. Zero uses the
+	// fiber default.
+	BodyLimit int
 }
 
 // New to create new web server.
 func New(cfg Config) Server {
 	return &server{
-		router: fiber.New(),
-		cfg:    cfg,
+		router: fiber.New(fiber.Config{
+			ReadTimeout:  cfg.ReadTimeout,
+			WriteTimeout: cfg.WriteTimeout,
+			BodyLimit:    cfg.BodyLimit,
+		}),
+		cfg: cfg,
 	}
 }
 
@@ -42,34 +46,21 @@ func (s *server) Router() *fiber.App {
 	return s.router
 }
 
-// Run to start serving HTTP.
+// Run to start serving HTTP. The channel gets the result once the server
+// stops, nil if it was closed.
 func (s *server) Run() chan error {
-	var ch = make(chan error)
+	var ch = make(chan error, 1)
 	go s.run(ch)
 	return ch
 }
 
 func (s *server) run(ch chan error) {
-	err := s.router.Listen(":" + s.cfg.Port)
-	if err != nil {
-		ch <- err
-		return
-	}
-
-	s.server = &http.Server{
-		ReadTimeout:  s.cfg.ReadTimeout,
-		WriteTimeout: s.cfg.WriteTimeout,
-	}
+	ch <- s.router.Listen(":" + s.cfg.Port)
 }
 
-// Close to stop the server gracefully.
+// Close to stop the server gracefully. It stops accepting connections and
+// waits for requests in flight, for at most the graceful timeout before
+// closing the rest.
 func (s *server) Close() error {
-	if s.server == nil {
-		return nil
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.GracefulTimeout)
-	defer cancel()
-
-	return s.server.Shutdown(ctx)
+	return s.router.ShutdownWithTimeout(s.cfg.GracefulTimeout)
 }
@@ -0,0 +1,146 @@
+package jsonpatch_test
+
+import (
+	"testing"
+
+	"hexagon-architecture/pkg/jsonpatch"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	tests := map[string]struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		"replace":       {doc: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		"add":           {doc: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		"remove":        {doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		"array-replace": {doc: `{"a":["b"]}`, patch: `{"a":["c"]}`, expected: `{"a":["c"]}`},
+		"nested":        {doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		"non-object":    {doc: `{"a":"b"}`, patch: `["c"]`, expected: `["c"]`},
+		"large-number":  {doc: `{"a":1}`, patch: `{"a":9007199254740993}`, expected: `{"a":9007199254740993}`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := jsonpatch.Merge([]byte(test.doc), []byte(test.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, test.expected, string(result))
+		})
+	}
+
+	_, err := jsonpatch.Merge([]byte(`{}`), []byte(`{`))
+	assert.Equal(t, jsonpatch.ErrInvalidPatch, err)
+}
+
+func TestApply(t *testing.T) {
+	tests := map[string]struct {
+		doc         string
+		patch       string
+		expected    string
+		expectedErr error
+	}{
+		"add-member": {
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expected: `{"foo":"bar","baz":"qux"}`,
+		},
+		"add-array-element": {
+			doc:      `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			expected: `{"foo":["bar","qux","baz"]}`,
+		},
+		"add-array-end": {
+			doc:      `{"foo":["bar"]}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":"qux"}]`,
+			expected: `{"foo":["bar","qux"]}`,
+		},
+		"add-null": {
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/foo","value":null}]`,
+			expected: `{"foo":null}`,
+		},
+		"remove": {
+			doc:      `{"foo":["bar","qux","baz"]}`,
+			patch:    `[{"op":"remove","path":"/foo/1"}]`,
+			expected: `{"foo":["bar","baz"]}`,
+		},
+		"replace": {
+			doc:      `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			expected: `{"baz":"boo","foo":"bar"}`,
+		},
+		"move": {
+			doc:      `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		"copy": {
+			doc:      `{"foo":["a"]}`,
+			patch:    `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"add","path":"/bar/-","value":"b"}]`,
+			expected: `{"foo":["a"],"bar":["a","b"]}`,
+		},
+		"escaped-path": {
+			doc:      `{"a/b":1,"m~n":2}`,
+			patch:    `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+			expected: `{"a/b":3}`,
+		},
+		"test": {
+			doc:      `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch:    `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2.0}]`,
+			expected: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		"test-failed": {
+			doc:         `{"baz":"qux"}`,
+			patch:       `[{"op":"test","path":"/baz","value":"bar"}]`,
+			expectedErr: jsonpatch.ErrTestFailed,
+		},
+		"replace-missing": {
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"replace","path":"/baz","value":"qux"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		"add-missing-parent": {
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		"index-out-of-range": {
+			doc:         `{"foo":["bar"]}`,
+			patch:       `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		"missing-value": {
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"add","path":"/baz"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		"move-into-child": {
+			doc:         `{"foo":{"bar":1}}`,
+			patch:       `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		"unknown-op": {
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"drop","path":"/foo"}]`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+		"not-array": {
+			doc:         `{"foo":"bar"}`,
+			patch:       `{"op":"remove","path":"/foo"}`,
+			expectedErr: jsonpatch.ErrInvalidPatch,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := jsonpatch.Apply([]byte(test.doc), []byte(test.patch))
+			assert.Equal(t, test.expectedErr, err)
+			if test.expectedErr == nil {
+				assert.JSONEq(t, test.expected, string(result))
+			}
+		})
+	}
+}
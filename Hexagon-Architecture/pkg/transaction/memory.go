@@ -0,0 +1,46 @@
+package transaction
+
+import (
+	"context"
+	"sync"
+)
+
+// Snapshotter is in-memory storage a transaction can roll back.
+type Snapshotter interface {
+	// Snapshot to copy the storage, returning a function putting it back
+	// as it was.
+	Snapshot() (restore func())
+}
+
+type memoryTransaction struct {
+	mu      sync.Mutex
+	storage []Snapshotter
+}
+
+// NewMemory to create transactions on in-memory storage. They run one at
+// a time, and storage is put back as it was when one fails, along with
+// anything written outside transactions meanwhile.
+func NewMemory(storage ...Snapshotter) Transaction {
+	return &memoryTransaction{
+		storage: storage,
+	}
+}
+
+func (t *memoryTransaction) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	restores := make([]func(), len(t.storage))
+	for i, storage := range t.storage {
+		restores[i] = storage.Snapshot()
+	}
+
+	if err := fn(ctx); err != nil {
+		for _, restore := range restores {
+			restore()
+		}
+		return err
+	}
+
+	return nil
+}
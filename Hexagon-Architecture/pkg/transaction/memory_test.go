@@ -0,0 +1,42 @@
+package transaction_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"hexagon-architecture/pkg/transaction"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// counter is storage holding one number.
+type counter struct {
+	n int
+}
+
+func (c *counter) Snapshot() func() {
+	n := c.n
+	return func() { c.n = n }
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	c := &counter{}
+	tx := transaction.NewMemory(c)
+
+	err := tx.Run(ctx, func(ctx context.Context) error {
+		c.n++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, c.n)
+
+	failed := errors.New("failed")
+	err = tx.Run(ctx, func(ctx context.Context) error {
+		c.n++
+		return failed
+	})
+	assert.Equal(t, failed, err)
+	assert.Equal(t, 1, c.n, "failed transaction is rolled back")
+}
@@ -0,0 +1,35 @@
+package transaction
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoTransaction struct {
+	client *mongo.Client
+}
+
+// NewMongo to create transactions run in mongo sessions. Mongo only has
+// transactions on replica sets and sharded clusters, a single node
+// replica set will do for development.
+func NewMongo(client *mongo.Client) Transaction {
+	return &mongoTransaction{
+		client: client,
+	}
+}
+
+func (t *mongoTransaction) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	// The session context carries the transaction to every collection
+	// call made with it.
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
@@ -0,0 +1,13 @@
+package transaction
+
+import "context"
+
+// Transaction runs writes to storage so either all of them are kept or
+// none are.
+type Transaction interface {
+	// Run to run fn in a transaction, committed when fn returns nil and
+	// rolled back otherwise. Storage only takes part through the context
+	// fn is given. fn may run more than once when the transaction is
+	// retried.
+	Run(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,137 @@
+// Package xlsx writes single sheet xlsx files row by row, without keeping
+// the rows in memory.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writer writes rows of an xlsx sheet. Close must be called to finish
+// the file.
+type Writer struct {
+	zip   *zip.Writer
+	sheet io.Writer
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// Fixed parts of a workbook with one sheet. The sheet itself goes last
+// so it can be written as rows come.
+var parts = []struct {
+	name    string
+	content string
+}{
+	{
+		name: "[Content_Types].xml",
+		content: xmlHeader +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`,
+	},
+	{
+		name: "_rels/.rels",
+		content: xmlHeader +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`,
+	},
+	{
+		name: "xl/_rels/workbook.xml.rels",
+		content: xmlHeader +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`,
+	},
+}
+
+const (
+	workbookFormat = xmlHeader +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	sheetHeader = xmlHeader +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	sheetFooter = `</sheetData></worksheet>`
+)
+
+// NewWriter to start writing an xlsx file with a sheet named sheetName.
+func NewWriter(w io.Writer, sheetName string) (*Writer, error) {
+	z := zip.NewWriter(w)
+
+	for _, part := range parts {
+		if err := writePart(z, part.name, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writePart(z, "xl/workbook.xml", fmt.Sprintf(workbookFormat, escape(sheetName))); err != nil {
+		return nil, err
+	}
+
+	sheet, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, sheetHeader); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		zip:   z,
+		sheet: sheet,
+	}, nil
+}
+
+func writePart(z *zip.Writer, name, content string) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, content)
+	return err
+}
+
+// WriteRow to write a row. Numbers become number cells and anything else
+// a text cell.
+func (w *Writer) WriteRow(values ...interface{}) error {
+	row := "<row>"
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case int:
+			row += `<c><v>` + strconv.Itoa(v) + `</v></c>`
+		case int64:
+			row += `<c><v>` + strconv.FormatInt(v, 10) + `</v></c>`
+		case float64:
+			row += `<c><v>` + strconv.FormatFloat(v, 'f', -1, 64) + `</v></c>`
+		default:
+			row += `<c t="inlineStr"><is><t xml:space="preserve">` + escape(fmt.Sprint(v)) + `</t></is></c>`
+		}
+	}
+
+	_, err := io.WriteString(w.sheet, row+"</row>")
+	return err
+}
+
+// Close to finish the sheet and the file. It doesn't close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if _, err := io.WriteString(w.sheet, sheetFooter); err != nil {
+		return err
+	}
+	return w.zip.Close()
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
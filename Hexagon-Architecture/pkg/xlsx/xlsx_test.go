@@ -0,0 +1,42 @@
+package xlsx_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"hexagon-architecture/pkg/xlsx"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	w, err := xlsx.NewWriter(&buf, "a<b")
+	assert.NoError(t, err)
+	assert.NoError(t, w.WriteRow("name", "stock"))
+	assert.NoError(t, w.WriteRow("Fish & Chips", 12))
+	assert.NoError(t, w.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	files := map[string]string{}
+	for _, f := range r.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	assert.Contains(t, files, "[Content_Types].xml")
+	assert.Contains(t, files, "_rels/.rels")
+	assert.Contains(t, files, "xl/_rels/workbook.xml.rels")
+	assert.Contains(t, files["xl/workbook.xml"], `<sheet name="a&lt;b"`)
+	assert.Contains(t, files["xl/worksheets/sheet1.xml"],
+		`<row><c t="inlineStr"><is><t xml:space="preserve">Fish &amp; Chips</t></is></c><c><v>12</v></c></row>`)
+}